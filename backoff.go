@@ -0,0 +1,67 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff = time.Minute
+)
+
+// BackoffPolicy define delay between retries. Delay starts with Backoff and is doubled after every attempt that fails,
+// but it is never bigger than MaxBackoff. Zero Backoff means 100 milliseconds and zero MaxBackoff means one minute.
+type BackoffPolicy struct {
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// backoff returns delays of BackoffPolicy one after another
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func (p BackoffPolicy) newBackoff() *backoff {
+	b := &backoff{initial: p.Backoff, max: p.MaxBackoff}
+	if b.initial <= 0 {
+		b.initial = defaultBackoff
+	}
+	if b.max <= 0 {
+		b.max = defaultMaxBackoff
+	}
+	if b.initial > b.max {
+		b.initial = b.max
+	}
+	b.current = b.initial
+	return b
+}
+
+// next returns delay before next attempt and doubles delay for the one after
+func (b *backoff) next() time.Duration {
+	d := b.current
+	b.current *= 2
+	if b.current > b.max {
+		b.current = b.max
+	}
+	return d
+}
+
+// reset starts again from initial delay, it is used after successful attempt
+func (b *backoff) reset() {
+	b.current = b.initial
+}
+
+// jitter returns random duration between half of d and d
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d - time.Duration(rand.Int63n(int64(d/2)+1))
+}
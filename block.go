@@ -0,0 +1,189 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// BlockDecodeError is returned in event response when received block cannot be decoded. Decoding will not succeed
+// after reconnect, so reconnecting listener and event service stop with it.
+type BlockDecodeError struct {
+	Number uint64
+	Err    error
+}
+
+func (e *BlockDecodeError) Error() string {
+	return fmt.Sprintf("block %d cannot be decoded: %v", e.Number, e.Err)
+}
+
+// Unwrap returns the decoding error
+func (e *BlockDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Block is decoded block with all envelopes and metadata
+type Block struct {
+	Number       uint64
+	PreviousHash []byte
+	DataHash     []byte
+	Envelopes    []*BlockEnvelope
+	Metadata     *BlockMetadata
+}
+
+// BlockEnvelope is single envelope (transaction) in the block.
+type BlockEnvelope struct {
+	ChannelHeader   *ChannelHeader
+	SignatureHeader *SignatureHeader
+	Signature       []byte
+	// ValidationCode is taken from transactions filter in block metadata. Blocks received from orderer are not
+	// validated yet, so it is always VALID for them.
+	ValidationCode peer.TxValidationCode
+	// Transaction is set for endorser transactions
+	Transaction *Transaction
+	// Config is set for config transactions
+	Config *common.ConfigEnvelope
+	// Data is raw payload data, it can be used for envelope types that are not decoded
+	Data []byte
+}
+
+// BlockMetadata is decoded block metadata
+type BlockMetadata struct {
+	// Signatures are orderer signatures of the block
+	Signatures []*BlockSignature
+	// LastConfig is number of the last configuration block
+	LastConfig uint64
+	// ValidationCodes are validation codes of every envelope in the block. It is empty for blocks from orderer.
+	ValidationCodes []peer.TxValidationCode
+	// Orderer is raw value of consensus specific metadata (for example Kafka offset)
+	Orderer []byte
+}
+
+// BlockSignature is orderer signature of the block
+type BlockSignature struct {
+	Creator   *TransactionIdentity
+	Nonce     []byte
+	Signature []byte
+}
+
+// DecodeBlock decodes block header, every envelope and block metadata.
+func DecodeBlock(block *common.Block) (*Block, error) {
+	if block == nil || block.Header == nil {
+		return nil, ErrInvalidBlock
+	}
+	result := &Block{
+		Number:       block.Header.Number,
+		PreviousHash: block.Header.PreviousHash,
+		DataHash:     block.Header.DataHash,
+	}
+	metadata, err := decodeBlockMetadata(block.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	result.Metadata = metadata
+	if block.Data == nil {
+		return result, nil
+	}
+	result.Envelopes = make([]*BlockEnvelope, 0, len(block.Data.Data))
+	for idx, data := range block.Data.Data {
+		envelope, err := decodeBlockEnvelope(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode envelope %d in block %d: %v", idx, result.Number, err)
+		}
+		if idx < len(metadata.ValidationCodes) {
+			envelope.ValidationCode = metadata.ValidationCodes[idx]
+		}
+		if envelope.Transaction != nil {
+			envelope.Transaction.ValidationCode = envelope.ValidationCode
+		}
+		result.Envelopes = append(result.Envelopes, envelope)
+	}
+	return result, nil
+}
+
+func decodeBlockEnvelope(data []byte) (*BlockEnvelope, error) {
+	envelope := new(common.Envelope)
+	if err := proto.Unmarshal(data, envelope); err != nil {
+		return nil, err
+	}
+	payload, channelHeader, signatureHeader, err := decodePayload(envelope.Payload)
+	if err != nil {
+		return nil, err
+	}
+	result := &BlockEnvelope{
+		ChannelHeader:   channelHeader,
+		SignatureHeader: signatureHeader,
+		Signature:       envelope.Signature,
+		Data:            payload.Data,
+	}
+	switch channelHeader.Type {
+	case common.HeaderType_ENDORSER_TRANSACTION:
+		if result.Transaction, err = newTransaction(channelHeader, signatureHeader, payload.Data); err != nil {
+			return nil, err
+		}
+	case common.HeaderType_CONFIG:
+		config := new(common.ConfigEnvelope)
+		if err := proto.Unmarshal(payload.Data, config); err != nil {
+			return nil, err
+		}
+		result.Config = config
+	}
+	return result, nil
+}
+
+func decodeBlockMetadata(metadata *common.BlockMetadata) (*BlockMetadata, error) {
+	result := new(BlockMetadata)
+	if metadata == nil {
+		return result, nil
+	}
+	if data := metadataAt(metadata, common.BlockMetadataIndex_SIGNATURES); len(data) > 0 {
+		signatures := new(common.Metadata)
+		if err := proto.Unmarshal(data, signatures); err != nil {
+			return nil, err
+		}
+		for _, s := range signatures.Signatures {
+			header, err := decodeSignatureHeader(s.SignatureHeader)
+			if err != nil {
+				return nil, err
+			}
+			result.Signatures = append(result.Signatures, &BlockSignature{Creator: header.Creator,
+				Nonce: header.Nonce, Signature: s.Signature})
+		}
+	}
+	if data := metadataAt(metadata, common.BlockMetadataIndex_LAST_CONFIG); len(data) > 0 {
+		lastConfigMetadata := new(common.Metadata)
+		if err := proto.Unmarshal(data, lastConfigMetadata); err != nil {
+			return nil, err
+		}
+		lastConfig := new(common.LastConfig)
+		if err := proto.Unmarshal(lastConfigMetadata.Value, lastConfig); err != nil {
+			return nil, err
+		}
+		result.LastConfig = lastConfig.Index
+	}
+	filter := metadataAt(metadata, common.BlockMetadataIndex_TRANSACTIONS_FILTER)
+	result.ValidationCodes = make([]peer.TxValidationCode, 0, len(filter))
+	for _, code := range filter {
+		result.ValidationCodes = append(result.ValidationCodes, peer.TxValidationCode(code))
+	}
+	if data := metadataAt(metadata, common.BlockMetadataIndex_ORDERER); len(data) > 0 {
+		ordererMetadata := new(common.Metadata)
+		if err := proto.Unmarshal(data, ordererMetadata); err != nil {
+			return nil, err
+		}
+		result.Orderer = ordererMetadata.Value
+	}
+	return result, nil
+}
+
+func metadataAt(metadata *common.BlockMetadata, index common.BlockMetadataIndex) []byte {
+	if int(index) >= len(metadata.Metadata) {
+		return nil
+	}
+	return metadata.Metadata[index]
+}
@@ -0,0 +1,121 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"github.com/hyperledger/fabric/protos/common"
+	"math/big"
+	"sync"
+)
+
+// BlockVerificationError is returned when block is tampered or does not follow previously verified block.
+type BlockVerificationError struct {
+	Number uint64
+	// Err is one of ErrInvalidBlock, ErrBlockDataHashMismatch, ErrBlockNumberGap or ErrBlockPreviousHashMismatch
+	Err error
+}
+
+func (e *BlockVerificationError) Error() string {
+	return fmt.Sprintf("block %d cannot be verified: %v", e.Number, e.Err)
+}
+
+// Unwrap allows errors.Is(err, ErrBlockDataHashMismatch) and others
+func (e *BlockVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// BlockVerifier checks that blocks are not tampered and that they form hash chain. For every block DataHash in the
+// header must match the block data, and every block must be next block after previously verified one, with
+// PreviousHash equal to the hash of its header. First verified block is trusted.
+// BlockVerifier is safe for concurrent use.
+type BlockVerifier struct {
+	mutex  sync.Mutex
+	number uint64
+	hash   []byte
+}
+
+// asn1Header is ASN.1 form of block header used by Fabric to compute header hash
+type asn1Header struct {
+	Number       *big.Int
+	PreviousHash []byte
+	DataHash     []byte
+}
+
+// NewBlockVerifier creates verifier that trusts the first block it sees.
+func NewBlockVerifier() *BlockVerifier {
+	return new(BlockVerifier)
+}
+
+// SetLast sets last trusted block, so next verified block must be number+1 with PreviousHash equal to headerHash.
+// Nil headerHash resets verifier to trust the next block it sees.
+func (v *BlockVerifier) SetLast(number uint64, headerHash []byte) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.number = number
+	v.hash = headerHash
+}
+
+// Last returns number and header hash of last verified block. Hash is nil if no block is verified yet.
+func (v *BlockVerifier) Last() (uint64, []byte) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.number, v.hash
+}
+
+// Verify checks the block and remembers it as last verified block.
+func (v *BlockVerifier) Verify(block *common.Block) error {
+	if block == nil || block.Header == nil || block.Data == nil {
+		return &BlockVerificationError{Err: ErrInvalidBlock}
+	}
+	number := block.Header.Number
+	if !bytes.Equal(BlockDataHash(block.Data), block.Header.DataHash) {
+		return &BlockVerificationError{Number: number, Err: ErrBlockDataHashMismatch}
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.hash != nil {
+		if number != v.number+1 {
+			return &BlockVerificationError{Number: number, Err: ErrBlockNumberGap}
+		}
+		if !bytes.Equal(block.Header.PreviousHash, v.hash) {
+			return &BlockVerificationError{Number: number, Err: ErrBlockPreviousHashMismatch}
+		}
+	}
+	hash, err := BlockHeaderHash(block.Header)
+	if err != nil {
+		return &BlockVerificationError{Number: number, Err: err}
+	}
+	v.number = number
+	v.hash = hash
+	return nil
+}
+
+// BlockHeaderHash computes hash of block header same way as Fabric, as SHA256 of ASN.1 encoded number, previous
+// hash and data hash. It is the PreviousHash of the next block.
+func BlockHeaderHash(header *common.BlockHeader) ([]byte, error) {
+	encoded, err := asn1.Marshal(asn1Header{
+		Number:       new(big.Int).SetUint64(header.Number),
+		PreviousHash: header.PreviousHash,
+		DataHash:     header.DataHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(encoded)
+	return h[:], nil
+}
+
+// BlockDataHash computes SHA256 over all envelopes in block data, it must be equal to DataHash in block header.
+func BlockDataHash(data *common.BlockData) []byte {
+	h := sha256.New()
+	for _, d := range data.Data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric/protos/common"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Expected hashes are SHA256 of DER encoded SEQUENCE { INTEGER number, OCTET STRING previousHash,
+// OCTET STRING dataHash }, same as Fabric computes block header hash. DER bytes are in comments.
+func TestBlockHeaderHash(t *testing.T) {
+	emptyDataHash := mustDecodeHex(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	tests := []struct {
+		name     string
+		header   *common.BlockHeader
+		expected string
+	}{
+		{
+			// 3027 020100 0400 0420e3b0...b855
+			name:     "genesis",
+			header:   &common.BlockHeader{Number: 0, DataHash: emptyDataHash},
+			expected: "9cae6f5c10756febc407bba95fc23853774c1f65f10354895bfdab6443d66ed3",
+		},
+		{
+			// 3048 02020080 04200101...01 04200202...02, number needs leading zero byte
+			name: "number 128",
+			header: &common.BlockHeader{Number: 128, PreviousHash: bytes.Repeat([]byte{1}, 32),
+				DataHash: bytes.Repeat([]byte{2}, 32)},
+			expected: "c0ffbca2c19ee62fc3e76d183167f01a4de3f8f27c83daf84ee54d30774edb24",
+		},
+	}
+	for _, test := range tests {
+		hash, err := BlockHeaderHash(test.header)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := hex.EncodeToString(hash); got != test.expected {
+			t.Errorf("%s: expected hash %s, got %s", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestBlockDataHash(t *testing.T) {
+	hash := BlockDataHash(&common.BlockData{Data: [][]byte{[]byte("tx1"), []byte("tx2")}})
+	expected := "b75fa4cca73a24cc129213c6e064b971533e2fa3eb0d118e3f484a7ae4a70fd3"
+	if got := hex.EncodeToString(hash); got != expected {
+		t.Fatalf("expected data hash %s, got %s", expected, got)
+	}
+}
+
+// verifyError checks that err is *BlockVerificationError for block number with reason expected
+func verifyError(t *testing.T, err error, number uint64, expected error) {
+	t.Helper()
+	verificationErr, ok := err.(*BlockVerificationError)
+	if !ok {
+		t.Fatalf("expected *BlockVerificationError, got %v", err)
+	}
+	if verificationErr.Number != number {
+		t.Errorf("expected error for block %d, got %d", number, verificationErr.Number)
+	}
+	if !errors.Is(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+}
+
+func TestBlockVerifier(t *testing.T) {
+	chain := testChain(t, 5)
+	verifier := NewBlockVerifier()
+	for _, b := range chain {
+		if err := verifier.Verify(b); err != nil {
+			t.Fatalf("block %d: %v", b.Header.Number, err)
+		}
+	}
+	number, hash := verifier.Last()
+	expected, _ := BlockHeaderHash(chain[4].Header)
+	if number != 4 || !bytes.Equal(hash, expected) {
+		t.Fatalf("expected last block 4 with its header hash, got %d", number)
+	}
+}
+
+func TestBlockVerifierDataHashMismatch(t *testing.T) {
+	chain := testChain(t, 2)
+	tampered := *chain[1]
+	tampered.Data = &common.BlockData{Data: [][]byte{[]byte("tampered")}}
+	verifier := NewBlockVerifier()
+	if err := verifier.Verify(chain[0]); err != nil {
+		t.Fatal(err)
+	}
+	verifyError(t, verifier.Verify(&tampered), 1, ErrBlockDataHashMismatch)
+	// failed block is not remembered
+	if number, _ := verifier.Last(); number != 0 {
+		t.Fatalf("expected last block 0, got %d", number)
+	}
+	if err := verifier.Verify(chain[1]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockVerifierNumberGap(t *testing.T) {
+	chain := testChain(t, 3)
+	verifier := NewBlockVerifier()
+	if err := verifier.Verify(chain[0]); err != nil {
+		t.Fatal(err)
+	}
+	verifyError(t, verifier.Verify(chain[2]), 2, ErrBlockNumberGap)
+	verifyError(t, verifier.Verify(chain[0]), 0, ErrBlockNumberGap)
+}
+
+func TestBlockVerifierPreviousHashMismatch(t *testing.T) {
+	chain := testChain(t, 2)
+	other := *chain[1].Header
+	other.PreviousHash = bytes.Repeat([]byte{1}, 32)
+	forked := &common.Block{Header: &other, Data: chain[1].Data}
+	verifier := NewBlockVerifier()
+	if err := verifier.Verify(chain[0]); err != nil {
+		t.Fatal(err)
+	}
+	verifyError(t, verifier.Verify(forked), 1, ErrBlockPreviousHashMismatch)
+}
+
+func TestBlockVerifierInvalidBlock(t *testing.T) {
+	verifier := NewBlockVerifier()
+	verifyError(t, verifier.Verify(nil), 0, ErrInvalidBlock)
+	verifyError(t, verifier.Verify(&common.Block{Header: &common.BlockHeader{}}), 0, ErrInvalidBlock)
+}
+
+func TestBlockVerifierSetLast(t *testing.T) {
+	chain := testChain(t, 8)
+	hash, err := BlockHeaderHash(chain[4].Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewBlockVerifier()
+	verifier.SetLast(4, hash)
+	if err := verifier.Verify(chain[5]); err != nil {
+		t.Fatalf("block after SetLast: %v", err)
+	}
+	verifyError(t, verifier.Verify(chain[7]), 7, ErrBlockNumberGap)
+
+	verifier.SetLast(6, bytes.Repeat([]byte{1}, 32))
+	verifyError(t, verifier.Verify(chain[7]), 7, ErrBlockPreviousHashMismatch)
+
+	// nil hash trusts next block
+	verifier.SetLast(0, nil)
+	if err := verifier.Verify(chain[7]); err != nil {
+		t.Fatalf("block after reset: %v", err)
+	}
+	if number, _ := verifier.Last(); number != 7 {
+		t.Fatalf("expected last block 7, got %d", number)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BroadcastPolicy define how signed transaction is send to orderers.
+type BroadcastPolicy struct {
+	// Orderers is ordered list of orderer names. Orderers are tried one after another until one of them accepts the
+	// transaction. If empty, all configured orderers are used in alphabetical order of their names.
+	Orderers []string
+	// Retries is how many times all Orderers will be tried again when they fail with connection error or with
+	// retriable status like SERVICE_UNAVAILABLE. Default 0 means every orderer is tried only once.
+	Retries int
+	// BackoffPolicy is the delay between retries
+	BackoffPolicy
+	// AttemptTimeout limits single attempt to single orderer, including dialing, so unreachable orderer does not use
+	// up the whole ctx before next orderer is tried. Default is 5 seconds.
+	AttemptTimeout time.Duration
+	// Fanout sends same signed envelope to all Orderers at once instead of one after another.
+	// Transaction is accepted when at least one orderer accepts it. Attempts to other orderers are cancelled as soon
+	// as one orderer accepts, but they may have already delivered the envelope.
+	Fanout bool
+}
+
+// defaultBroadcastAttemptTimeout is used when BroadcastPolicy.AttemptTimeout is not set
+const defaultBroadcastAttemptTimeout = time.Second * time.Duration(timeout)
+
+// BroadcastAttempt is the outcome of sending transaction to single orderer.
+type BroadcastAttempt struct {
+	// Orderer is the name of the orderer.
+	Orderer string
+	// Retry is zero for first round of attempts, one for the first retry and so on.
+	Retry int
+	// Status is the status returned from orderer. It is not set when Err is connection error.
+	Status common.Status
+	Err    error
+}
+
+// BroadcastResult holds the response from orderer that accepted the transaction and all attempts made.
+type BroadcastResult struct {
+	Orderer  string
+	Response *orderer.BroadcastResponse
+	Attempts []*BroadcastAttempt
+}
+
+// BroadcastError is returned when no orderer accepted the transaction.
+type BroadcastError struct {
+	Attempts []*BroadcastAttempt
+}
+
+func (e *BroadcastError) Error() string {
+	msg := make([]string, 0, len(e.Attempts))
+	for _, a := range e.Attempts {
+		msg = append(msg, fmt.Sprintf("%s: %v", a.Orderer, a.Err))
+	}
+	return "transaction was not accepted by any orderer: " + strings.Join(msg, "; ")
+}
+
+// Broadcast sends signed envelope to orderers according to policy.
+// If no orderer accepts the envelope error of type *BroadcastError is returned with all attempts.
+func (c *FabricClient) Broadcast(ctx context.Context, envelope *common.Envelope, policy BroadcastPolicy) (*BroadcastResult, error) {
+	orderers, err := c.getOrderers(policy.Orderers)
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]*BroadcastAttempt, 0, len(orderers)*(policy.Retries+1))
+	backoff := policy.newBackoff()
+	for retry := 0; retry <= policy.Retries; retry++ {
+		if retry > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, &BroadcastError{Attempts: attempts}
+			case <-time.After(backoff.next()):
+			}
+		}
+		var round []*BroadcastAttempt
+		var accepted *BroadcastResult
+		if policy.Fanout {
+			round, accepted = broadcastParallel(ctx, orderers, envelope, retry, policy.attemptTimeout())
+		} else {
+			round, accepted = broadcastSequential(ctx, orderers, envelope, retry, policy.attemptTimeout())
+		}
+		attempts = append(attempts, round...)
+		if accepted != nil {
+			accepted.Attempts = attempts
+			return accepted, nil
+		}
+		if !retriable(round) || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, &BroadcastError{Attempts: attempts}
+}
+
+// broadcastSequential sends envelope to orderers one by one until one of them accepts it.
+// It stops early if orderer rejects the envelope with status that will not change on another orderer.
+func broadcastSequential(ctx context.Context, orderers []*Orderer, envelope *common.Envelope, retry int, timeout time.Duration) ([]*BroadcastAttempt, *BroadcastResult) {
+	attempts := make([]*BroadcastAttempt, 0, len(orderers))
+	for _, o := range orderers {
+		attempt, response := broadcastAttempt(ctx, o, envelope, retry, timeout)
+		attempts = append(attempts, attempt)
+		if attempt.Err == nil {
+			return attempts, &BroadcastResult{Orderer: o.Name, Response: response}
+		}
+		if !attempt.retriable() || ctx.Err() != nil {
+			break
+		}
+	}
+	return attempts, nil
+}
+
+// broadcastParallel sends envelope to all orderers at once. As soon as one orderer accepts the envelope other
+// attempts are cancelled. All attempts are finished when it returns.
+func broadcastParallel(ctx context.Context, orderers []*Orderer, envelope *common.Envelope, retry int, timeout time.Duration) ([]*BroadcastAttempt, *BroadcastResult) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	attempts := make([]*BroadcastAttempt, len(orderers))
+	responses := make([]*orderer.BroadcastResponse, len(orderers))
+	accepted := make(chan int, len(orderers))
+	var wg sync.WaitGroup
+	for i, o := range orderers {
+		wg.Add(1)
+		go func(i int, o *Orderer) {
+			defer wg.Done()
+			attempts[i], responses[i] = broadcastAttempt(ctx, o, envelope, retry, timeout)
+			if attempts[i].Err == nil {
+				accepted <- i
+			}
+		}(i, o)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case i := <-accepted:
+		cancel()
+		<-done
+		return attempts, &BroadcastResult{Orderer: attempts[i].Orderer, Response: responses[i]}
+	case <-done:
+	}
+	select {
+	case i := <-accepted:
+		return attempts, &BroadcastResult{Orderer: attempts[i].Orderer, Response: responses[i]}
+	default:
+		return attempts, nil
+	}
+}
+
+// broadcastAttempt sends envelope to single orderer. Attempt is limited with timeout.
+func broadcastAttempt(ctx context.Context, o *Orderer, envelope *common.Envelope, retry int, timeout time.Duration) (*BroadcastAttempt, *orderer.BroadcastResponse) {
+	attempt := &BroadcastAttempt{Orderer: o.Name, Retry: retry}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	response, err := o.send(ctx, envelope)
+	if err != nil {
+		attempt.Err = err
+		return attempt, nil
+	}
+	attempt.Status = response.Status
+	if response.Status != common.Status_SUCCESS {
+		attempt.Err = fmt.Errorf("unexpected status: %v", response.Status)
+		return attempt, nil
+	}
+	return attempt, response
+}
+
+func (p BroadcastPolicy) attemptTimeout() time.Duration {
+	if p.AttemptTimeout <= 0 {
+		return defaultBroadcastAttemptTimeout
+	}
+	return p.AttemptTimeout
+}
+
+// retriable reports whether attempt failed for reason that may disappear on retry or on another orderer.
+// Connection errors (no status), SERVICE_UNAVAILABLE and INTERNAL_SERVER_ERROR are considered temporary.
+// Other statuses like BAD_REQUEST or FORBIDDEN will be same on every orderer.
+func (a *BroadcastAttempt) retriable() bool {
+	switch a.Status {
+	case common.Status_UNKNOWN, common.Status_SERVICE_UNAVAILABLE, common.Status_INTERNAL_SERVER_ERROR:
+		return true
+	}
+	return false
+}
+
+// retriable reports whether any of the attempts can be retried.
+func retriable(attempts []*BroadcastAttempt) bool {
+	for _, a := range attempts {
+		if a.retriable() {
+			return true
+		}
+	}
+	return false
+}
+
+// getOrderers returns orderers with provided names preserving the order. If names is empty all orderers are
+// returned sorted by name.
+func (c *FabricClient) getOrderers(names []string) ([]*Orderer, error) {
+	if len(names) == 0 {
+		names = make([]string, 0, len(c.Orderers))
+		for name := range c.Orderers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		return nil, ErrInvalidOrdererName
+	}
+	res := make([]*Orderer, 0, len(names))
+	for _, name := range names {
+		o, ok := c.Orderers[name]
+		if !ok {
+			return nil, ErrInvalidOrdererName
+		}
+		res = append(res, o)
+	}
+	return res, nil
+}
@@ -1234,16 +1234,9 @@ func concatErrors(errs []caResponseErr) (error) {
 // NewCaClientFromConfig creates new FabricCAClient from CAConfig
 func NewCaClientFromConfig(config CAConfig, transport *http.Transport) (*FabricCAClient, error) {
 
-	var crypto CryptoSuite
-	var err error
-	switch config.CryptoConfig.Family {
-	case "ecdsa":
-		crypto, err = NewECCryptSuiteFromConfig(config.CryptoConfig)
-		if err != nil {
-			return nil, err
-		}
-	default:
-		return nil, ErrInvalidAlgorithmFamily
+	crypto, err := NewCryptoSuiteFromConfig(config.CryptoConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	return &FabricCAClient{SkipTLSVerification: config.SkipTLSValidation,
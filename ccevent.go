@@ -0,0 +1,251 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyperledger/fabric/protos/peer"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultChaincodeEventBuffer is used when ChaincodeEventOptions.Buffer is not set
+const defaultChaincodeEventBuffer = 100
+
+// ChaincodeEvent is event emitted by chaincode in committed transaction
+type ChaincodeEvent struct {
+	ChainCodeId string
+	EventName   string
+	TxID        string
+	BlockNumber uint64
+	// ValidationCode is validation result of the transaction. Events from invalid transactions are delivered too,
+	// unless ValidOnly option is used.
+	ValidationCode peer.TxValidationCode
+	// Payload is event payload. It is available only when FullBlock option is used.
+	Payload []byte
+}
+
+// ChaincodeEventOptions define how events are received for `client.RegisterChaincodeEvent`.
+type ChaincodeEventOptions struct {
+	// ValidOnly delivers only events from valid transactions
+	ValidOnly bool
+	// FullBlock receives full blocks, so event payload is available. Filtered blocks are used by default.
+	FullBlock bool
+	// EventPeers are names of event peers used to receive blocks. If empty all configured event peers are used.
+	EventPeers []string
+	// Buffer is the size of Events channel. Default is 100. When buffer is full, delivery of new events waits until
+	// events are read, so slow consumer slows down all registrations in the channel.
+	Buffer int
+}
+
+// ChaincodeEventRegistration receives events of one chaincode with names matching regular expression.
+// Events channel is closed on `Unregister` or when event stream fails, in that case `Err` returns the error.
+type ChaincodeEventRegistration struct {
+	Events <-chan *ChaincodeEvent
+	events chan *ChaincodeEvent
+	// done is closed on unregister, so pending delivery does not block
+	done        chan struct{}
+	doneOnce    sync.Once
+	mutex       sync.Mutex
+	closed      bool
+	errMutex    sync.Mutex
+	err         error
+	chainCodeId string
+	eventName   *regexp.Regexp
+	validOnly   bool
+	hub         *chaincodeEventHub
+}
+
+// chaincodeEventHub receives blocks of one channel with one event service and dispatches chaincode events to all
+// registrations. It is stopped when last registration is removed.
+type chaincodeEventHub struct {
+	key           string
+	client        *FabricClient
+	cancel        context.CancelFunc
+	mutex         sync.Mutex
+	registrations map[*ChaincodeEventRegistration]struct{}
+	stopped       bool
+}
+
+// RegisterChaincodeEvent registers for events of chainCodeId in channel, which names match eventNameRegex.
+// Regular expression is not anchored, use "^name$" for exact match.
+// All registrations in the same channel with same peers and block type share one event service, which is started on
+// first registration using identity from that call. Events are delivered from blocks committed after the service is
+// started, starting with the newest block at that time.
+func (c *FabricClient) RegisterChaincodeEvent(identity Identity, channelId, chainCodeId, eventNameRegex string,
+	options ChaincodeEventOptions) (*ChaincodeEventRegistration, error) {
+	eventName, err := regexp.Compile(eventNameRegex)
+	if err != nil {
+		return nil, err
+	}
+	if options.Buffer <= 0 {
+		options.Buffer = defaultChaincodeEventBuffer
+	}
+	eventPeers := options.EventPeers
+	if len(eventPeers) == 0 {
+		for name := range c.EventPeers {
+			eventPeers = append(eventPeers, name)
+		}
+		sort.Strings(eventPeers)
+	}
+	listenerType := EventTypeFiltered
+	if options.FullBlock {
+		listenerType = EventTypeFullBlock
+	}
+	events := make(chan *ChaincodeEvent, options.Buffer)
+	registration := &ChaincodeEventRegistration{
+		Events:      events,
+		events:      events,
+		done:        make(chan struct{}),
+		chainCodeId: chainCodeId,
+		eventName:   eventName,
+		validOnly:   options.ValidOnly,
+	}
+	key := fmt.Sprintf("%s/%d/%s", channelId, listenerType, strings.Join(eventPeers, ","))
+
+	c.eventHubsMutex.Lock()
+	defer c.eventHubsMutex.Unlock()
+	hub, ok := c.eventHubs[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		service, err := c.NewEventService(ctx, identity, channelId, eventPeers, listenerType, EventServiceOptions{})
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		hub = &chaincodeEventHub{key: key, client: c, cancel: cancel,
+			registrations: make(map[*ChaincodeEventRegistration]struct{})}
+		ch := make(chan EventBlockResponse)
+		service.Listen(ch)
+		go hub.receive(ch)
+		if c.eventHubs == nil {
+			c.eventHubs = make(map[string]*chaincodeEventHub)
+		}
+		c.eventHubs[key] = hub
+	}
+	registration.hub = hub
+	hub.mutex.Lock()
+	hub.registrations[registration] = struct{}{}
+	hub.mutex.Unlock()
+	return registration, nil
+}
+
+// Unregister stops delivery of events and closes Events channel. Events already in the channel can still be read.
+func (r *ChaincodeEventRegistration) Unregister() {
+	r.hub.remove(r)
+	r.close(nil)
+}
+
+// Err returns the error that closed Events channel, or nil if channel is not closed or it was closed by Unregister.
+func (r *ChaincodeEventRegistration) Err() error {
+	r.errMutex.Lock()
+	defer r.errMutex.Unlock()
+	return r.err
+}
+
+func (r *ChaincodeEventRegistration) close(err error) {
+	r.doneOnce.Do(func() { close(r.done) })
+	// delivery holds the mutex while sending, done unblocks it
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.errMutex.Lock()
+	r.err = err
+	r.errMutex.Unlock()
+	close(r.events)
+}
+
+// deliver sends event unless registration is closed meanwhile
+func (r *ChaincodeEventRegistration) deliver(event *ChaincodeEvent) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.events <- event:
+	case <-r.done:
+	}
+}
+
+func (r *ChaincodeEventRegistration) matches(chainCodeId, eventName string, code peer.TxValidationCode) bool {
+	if r.validOnly && code != peer.TxValidationCode_VALID {
+		return false
+	}
+	return r.chainCodeId == chainCodeId && r.eventName.MatchString(eventName)
+}
+
+func (h *chaincodeEventHub) receive(ch <-chan EventBlockResponse) {
+	for block := range ch {
+		if block.Error != nil {
+			h.fail(block.Error)
+			return
+		}
+		h.mutex.Lock()
+		registrations := make([]*ChaincodeEventRegistration, 0, len(h.registrations))
+		for r := range h.registrations {
+			registrations = append(registrations, r)
+		}
+		h.mutex.Unlock()
+		for _, tx := range block.Transactions {
+			code := peer.TxValidationCode(peer.TxValidationCode_value[tx.Status])
+			for _, e := range tx.Events {
+				for _, r := range registrations {
+					if r.matches(e.ChainCodeId, e.Name, code) {
+						r.deliver(&ChaincodeEvent{ChainCodeId: e.ChainCodeId, EventName: e.Name, TxID: tx.Id,
+							BlockNumber: block.BlockHeight, ValidationCode: code, Payload: e.Value})
+					}
+				}
+			}
+		}
+	}
+	// service is stopped and its last error is dropped, it happens only when hub is cancelled
+	h.fail(context.Canceled)
+}
+
+// fail closes all registrations with error from event service
+func (h *chaincodeEventHub) fail(err error) {
+	h.client.eventHubsMutex.Lock()
+	if h.client.eventHubs[h.key] == h {
+		delete(h.client.eventHubs, h.key)
+	}
+	h.client.eventHubsMutex.Unlock()
+	h.mutex.Lock()
+	h.stopped = true
+	registrations := h.registrations
+	h.registrations = make(map[*ChaincodeEventRegistration]struct{})
+	h.mutex.Unlock()
+	for r := range registrations {
+		r.close(err)
+	}
+}
+
+// remove removes registration and stops the hub when it was the last one
+func (h *chaincodeEventHub) remove(r *ChaincodeEventRegistration) {
+	h.client.eventHubsMutex.Lock()
+	defer h.client.eventHubsMutex.Unlock()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.registrations, r)
+	if len(h.registrations) > 0 || h.stopped {
+		return
+	}
+	h.stopped = true
+	if h.client.eventHubs[h.key] == h {
+		delete(h.client.eventHubs, h.key)
+	}
+	h.cancel()
+}
+
+// close stops the hub, registrations are closed with context error
+func (h *chaincodeEventHub) close() {
+	h.cancel()
+}
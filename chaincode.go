@@ -89,7 +89,7 @@ type ChainCodesResponse struct {
 
 // createInstallProposal read chaincode from provided source and namespace, pack it and generate install proposal
 // transaction. Transaction is not send from this func
-func createInstallProposal(identity Identity, req *InstallRequest) (*transactionProposal, error) {
+func createInstallProposal(identity Identity, req *InstallRequest, tlsCertHash []byte) (*transactionProposal, error) {
 
 	var packageBytes []byte
 	var err error
@@ -132,7 +132,7 @@ func createInstallProposal(identity Identity, req *InstallRequest) (*transaction
 	}
 	ccHdrExt := &peer.ChaincodeHeaderExtension{ChaincodeId: &peer.ChaincodeID{Name: LSCC}}
 
-	channelHeaderBytes, err := channelHeader(common.HeaderType_ENDORSER_TRANSACTION, txId, req.ChannelId, 0, ccHdrExt)
+	channelHeaderBytes, err := channelHeader(common.HeaderType_ENDORSER_TRANSACTION, txId, req.ChannelId, 0, ccHdrExt, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -165,7 +165,7 @@ func createInstallProposal(identity Identity, req *InstallRequest) (*transaction
 
 // createInstantiateProposal creates instantiate proposal transaction for already installed chaincode.
 // transaction is not send from this func
-func createInstantiateProposal(identity Identity, req *ChainCode, operation string, collectionConfig []byte) (*transactionProposal, error) {
+func createInstantiateProposal(identity Identity, req *ChainCode, operation string, collectionConfig []byte, tlsCertHash []byte) (*transactionProposal, error) {
 	if operation != "deploy" && operation != "upgrade" {
 		return nil, fmt.Errorf("install proposall accept only 'deploy' and 'upgrade' operations")
 	}
@@ -218,7 +218,7 @@ func createInstantiateProposal(identity Identity, req *ChainCode, operation stri
 	}
 	headerExtension := &peer.ChaincodeHeaderExtension{ChaincodeId: &peer.ChaincodeID{Name: LSCC}}
 
-	channelHeaderBytes, err := channelHeader(common.HeaderType_ENDORSER_TRANSACTION, txId, req.ChannelId, 0, headerExtension)
+	channelHeaderBytes, err := channelHeader(common.HeaderType_ENDORSER_TRANSACTION, txId, req.ChannelId, 0, headerExtension, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
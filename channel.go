@@ -78,7 +78,7 @@ func buildAndSignChannelConfig(identity Identity, configPayload []byte, crypto C
 	configSignature.Signature = sig
 	configUpdateEnvelope.Signatures = append(configUpdateEnvelope.GetSignatures(), configSignature)
 
-	channelHeaderBytes, err := channelHeader(common.HeaderType_CONFIG_UPDATE, txId, channelId,0,nil)
+	channelHeaderBytes, err := channelHeader(common.HeaderType_CONFIG_UPDATE, txId, channelId,0,nil, nil)
 	header := header(sigHeaderBytes, channelHeaderBytes)
 
 	envelopeBytes, err := proto.Marshal(configUpdateEnvelope)
@@ -0,0 +1,129 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CheckpointStore persists number of the last block processed by event listener, so listener can continue from the
+// next block after reconnect or restart. Key identifies the listener, so many listeners can share one store.
+// Implementations must be safe for concurrent use.
+type CheckpointStore interface {
+	// Load returns last processed block. ok is false if there is no checkpoint for the key.
+	Load(key string) (blockNumber uint64, ok bool, err error)
+	// Save stores last processed block
+	Save(key string, blockNumber uint64) error
+}
+
+// MemoryCheckpointStore keeps checkpoints in memory. Checkpoints survive reconnects but not restarts.
+type MemoryCheckpointStore struct {
+	mutex       sync.Mutex
+	checkpoints map[string]uint64
+}
+
+// FileCheckpointStore keeps all checkpoints in one JSON file. File is written to temporary file and renamed on every
+// save, so it is never left half written.
+type FileCheckpointStore struct {
+	path        string
+	mutex       sync.Mutex
+	checkpoints map[string]uint64
+}
+
+// NewMemoryCheckpointStore creates empty in memory store
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string]uint64)}
+}
+
+// Load returns checkpoint for the key
+func (s *MemoryCheckpointStore) Load(key string) (uint64, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.checkpoints[key]
+	return n, ok, nil
+}
+
+// Save stores checkpoint for the key
+func (s *MemoryCheckpointStore) Save(key string, blockNumber uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checkpoints[key] = blockNumber
+	return nil
+}
+
+// NewFileCheckpointStore creates store in file path. Existing checkpoints are loaded from the file if it exists.
+func NewFileCheckpointStore(path string) (*FileCheckpointStore, error) {
+	s := &FileCheckpointStore{path: path, checkpoints: make(map[string]uint64)}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.checkpoints); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load returns checkpoint for the key
+func (s *FileCheckpointStore) Load(key string) (uint64, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.checkpoints[key]
+	return n, ok, nil
+}
+
+// Save stores checkpoint for the key and writes all checkpoints to the file
+func (s *FileCheckpointStore) Save(key string, blockNumber uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	previous, existed := s.checkpoints[key]
+	s.checkpoints[key] = blockNumber
+	if err := s.write(); err != nil {
+		if existed {
+			s.checkpoints[key] = previous
+		} else {
+			delete(s.checkpoints, key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileCheckpointStore) write() error {
+	data, err := json.Marshal(s.checkpoints)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	store, err := NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatalf("missing file must give empty store: %v", err)
+	}
+	if _, ok, err := store.Load("mychannel"); ok || err != nil {
+		t.Fatalf("expected no checkpoint in empty store, got ok=%v err=%v", ok, err)
+	}
+	if err := store.Save("mychannel", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("mychannel", 11); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("otherchannel", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileCheckpointStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]uint64{"mychannel": 11, "otherchannel": 3} {
+		n, ok, err := reopened.Load(key)
+		if err != nil || !ok || n != expected {
+			t.Errorf("%s: expected checkpoint %d, got %d ok=%v err=%v", key, expected, n, ok, err)
+		}
+	}
+	if _, ok, _ := reopened.Load("unknown"); ok {
+		t.Error("expected no checkpoint for unknown key")
+	}
+
+	// only checkpoint file is left, temporary files are renamed
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only checkpoint file, got %v", files)
+	}
+}
+
+func TestFileCheckpointStoreCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.json")
+	if err := ioutil.WriteFile(path, []byte(`{"mychannel": 1`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileCheckpointStore(path); err == nil {
+		t.Fatal("expected error for corrupt checkpoint file")
+	}
+}
+
+func TestFileCheckpointStoreSaveFailure(t *testing.T) {
+	store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "missing", "checkpoints.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("mychannel", 1); err == nil {
+		t.Fatal("expected error when directory does not exist")
+	}
+	// failed save is not visible
+	if _, ok, _ := store.Load("mychannel"); ok {
+		t.Fatal("expected checkpoint of failed save to be dropped")
+	}
+}
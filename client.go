@@ -12,6 +12,8 @@ import (
 	"github.com/hyperledger/fabric/protos/orderer"
 	"context"
 	"fmt"
+	"bytes"
+	"sync"
 )
 
 // FabricClient expose API's to work with Hyperledger Fabric
@@ -20,11 +22,22 @@ type FabricClient struct {
 	Peers      map[string]*Peer
 	Orderers   map[string]*Orderer
 	EventPeers map[string]*Peer
+	// EndorsementVerifier checks endorsements before transaction is send to orderer. If nil endorsements are not checked.
+	EndorsementVerifier *EndorsementVerifier
+	notifiersMutex      sync.Mutex
+	notifiers           map[string]*CommitNotifier
+	eventHubsMutex      sync.Mutex
+	eventHubs           map[string]*chaincodeEventHub
 }
 
 // CreateUpdateChannel read channel config generated (usually) from configtxgen and send it to orderer
 // This step is needed before any peer is able to join the channel and before any future updates of the channel.
 func (c *FabricClient) CreateUpdateChannel(identity Identity, path string, channelId string, orderer string) (error) {
+	return c.CreateUpdateChannelContext(context.Background(), identity, path, channelId, orderer)
+}
+
+// CreateUpdateChannelContext is same as CreateUpdateChannel, but uses ctx for dialing and for every request.
+func (c *FabricClient) CreateUpdateChannelContext(ctx context.Context, identity Identity, path string, channelId string, orderer string) (error) {
 
 	ord, ok := c.Orderers[orderer]
 	if !ok {
@@ -39,7 +52,7 @@ func (c *FabricClient) CreateUpdateChannel(identity Identity, path string, chann
 	if err != nil {
 		return err
 	}
-	replay, err := ord.Broadcast(ou)
+	replay, err := ord.BroadcastContext(ctx, ou)
 	if err != nil {
 		return err
 	}
@@ -53,6 +66,12 @@ func (c *FabricClient) CreateUpdateChannel(identity Identity, path string, chann
 // Channel must be created before this operation using `CreateUpdateChannel` or manually using CLI interface.
 // Orderers must be aware of this channel, otherwise operation will fail.
 func (c *FabricClient) JoinChannel(identity Identity, channelId string, peers []string, orderer string) ([]*PeerResponse, error) {
+	return c.JoinChannelContext(context.Background(), identity, channelId, peers, orderer)
+}
+
+// JoinChannelContext is same as JoinChannel, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) JoinChannelContext(ctx context.Context, identity Identity, channelId string, peers []string, orderer string) ([]*PeerResponse, error) {
 	ord, ok := c.Orderers[orderer]
 	if !ok {
 		return nil, ErrInvalidOrdererName
@@ -63,7 +82,7 @@ func (c *FabricClient) JoinChannel(identity Identity, channelId string, peers []
 		return nil, ErrPeerNameNotFound
 	}
 
-	block, err := ord.getGenesisBlock(identity, c.Crypto, channelId)
+	block, err := ord.getGenesisBlock(ctx, identity, c.Crypto, channelId)
 
 	if err != nil {
 		return nil, err
@@ -91,8 +110,12 @@ func (c *FabricClient) JoinChannel(identity Identity, channelId string, peers []
 	if err != nil {
 		return nil, err
 	}
+	tlsCertHash, err := c.tlsCertHash(execPeers)
+	if err != nil {
+		return nil, err
+	}
 	ext := &peer.ChaincodeHeaderExtension{ChaincodeId: &peer.ChaincodeID{Name: CSCC}}
-	channelHeaderBytes, err := channelHeader(common.HeaderType_ENDORSER_TRANSACTION, txId, "", 0, ext)
+	channelHeaderBytes, err := channelHeader(common.HeaderType_ENDORSER_TRANSACTION, txId, "", 0, ext, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -124,16 +147,26 @@ func (c *FabricClient) JoinChannel(identity Identity, channelId string, peers []
 	if err != nil {
 		return nil, err
 	}
-	return sendToPeers(execPeers, proposal), nil
+	return sendToPeers(ctx, execPeers, proposal), nil
 }
 
 // InstallChainCode install chainCode to one or many peers. Peer must be in the channel where chaincode will be installed.
 func (c *FabricClient) InstallChainCode(identity Identity, req *InstallRequest, peers []string) ([]*PeerResponse, error) {
+	return c.InstallChainCodeContext(context.Background(), identity, req, peers)
+}
+
+// InstallChainCodeContext is same as InstallChainCode, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) InstallChainCodeContext(ctx context.Context, identity Identity, req *InstallRequest, peers []string) ([]*PeerResponse, error) {
 	execPeers := c.getPeers(peers)
 	if len(peers) != len(execPeers) {
 		return nil, ErrPeerNameNotFound
 	}
-	prop, err := createInstallProposal(identity, req)
+	tlsCertHash, err := c.tlsCertHash(execPeers)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := createInstallProposal(identity, req, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +174,7 @@ func (c *FabricClient) InstallChainCode(identity Identity, req *InstallRequest,
 	if err != nil {
 		return nil, err
 	}
-	return sendToPeers(execPeers, proposal), nil
+	return sendToPeers(ctx, execPeers, proposal), nil
 
 }
 
@@ -153,10 +186,31 @@ func (c *FabricClient) InstallChainCode(identity Identity, req *InstallRequest,
 // will be created. collectionsConfig can be specified when chaincode is upgraded.
 func (c *FabricClient) InstantiateChainCode(identity Identity, req *ChainCode, peers []string, orderer string,
 	operation string, collectionsConfig []CollectionConfig) (*orderer.BroadcastResponse, error) {
-	ord, ok := c.Orderers[orderer]
-	if !ok {
+	return c.InstantiateChainCodeContext(context.Background(), identity, req, peers, orderer, operation, collectionsConfig)
+}
+
+// InstantiateChainCodeContext is same as InstantiateChainCode, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) InstantiateChainCodeContext(ctx context.Context, identity Identity, req *ChainCode, peers []string, orderer string,
+	operation string, collectionsConfig []CollectionConfig) (*orderer.BroadcastResponse, error) {
+	if _, ok := c.Orderers[orderer]; !ok {
 		return nil, ErrInvalidOrdererName
 	}
+	result, err := c.InstantiateChainCodeWithPolicy(ctx, identity, req, peers, BroadcastPolicy{Orderers: []string{orderer}},
+		operation, collectionsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return result.Response, nil
+}
+
+// InstantiateChainCodeWithPolicy is same as InstantiateChainCodeContext, but endorsed transaction is send to orderers
+// according to policy. Returned result holds orderer response and all attempts to send the transaction.
+func (c *FabricClient) InstantiateChainCodeWithPolicy(ctx context.Context, identity Identity, req *ChainCode, peers []string,
+	policy BroadcastPolicy, operation string, collectionsConfig []CollectionConfig) (*BroadcastResult, error) {
+	if _, err := c.getOrderers(policy.Orderers); err != nil {
+		return nil, err
+	}
 
 	execPeers := c.getPeers(peers)
 	if len(peers) != len(execPeers) {
@@ -174,35 +228,45 @@ func (c *FabricClient) InstantiateChainCode(identity Identity, req *ChainCode, p
 		}
 	}
 
-	prop, err := createInstantiateProposal(identity, req, operation, collConfigBytes)
+	tlsCertHash, err := c.tlsCertHash(execPeers)
 	if err != nil {
 		return nil, err
 	}
-
-	proposal, err := signedProposal(prop.proposal, identity, c.Crypto)
+	prop, err := createInstantiateProposal(identity, req, operation, collConfigBytes, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
 
-	transaction, err := createTransaction(prop.proposal, sendToPeers(execPeers, proposal))
+	proposal, err := signedProposal(prop.proposal, identity, c.Crypto)
 	if err != nil {
 		return nil, err
 	}
 
-	signedTransaction, err := c.Crypto.Sign(transaction, identity.PrivateKey)
+	responses := sendToPeers(ctx, execPeers, proposal)
+	if err := c.verifyEndorsements(prop.proposal, responses); err != nil {
+		return nil, err
+	}
+	transaction, err := createTransaction(prop.proposal, responses)
 	if err != nil {
 		return nil, err
 	}
 
-	reply, err := ord.Broadcast(&common.Envelope{Payload: transaction, Signature: signedTransaction})
+	signedTransaction, err := c.Crypto.Sign(transaction, identity.PrivateKey)
 	if err != nil {
 		return nil, err
 	}
-	return reply, nil
+
+	return c.Broadcast(ctx, &common.Envelope{Payload: transaction, Signature: signedTransaction}, policy)
 }
 
 // QueryInstalledChainCodes get all chainCodes that are installed but not instantiated in one or many peers
 func (c *FabricClient) QueryInstalledChainCodes(identity Identity, peers []string) ([]*ChainCodesResponse, error) {
+	return c.QueryInstalledChainCodesContext(context.Background(), identity, peers)
+}
+
+// QueryInstalledChainCodesContext is same as QueryInstalledChainCodes, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) QueryInstalledChainCodesContext(ctx context.Context, identity Identity, peers []string) ([]*ChainCodesResponse, error) {
 	execPeers := c.getPeers(peers)
 	if len(peers) != len(execPeers) {
 		return nil, ErrPeerNameNotFound
@@ -216,7 +280,11 @@ func (c *FabricClient) QueryInstalledChainCodes(identity Identity, peers []strin
 		Args: []string{"getinstalledchaincodes"},
 	}
 
-	prop, err := createTransactionProposal(identity, chainCode)
+	tlsCertHash, err := c.tlsCertHash(execPeers)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := createTransactionProposal(identity, chainCode, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -225,7 +293,7 @@ func (c *FabricClient) QueryInstalledChainCodes(identity Identity, peers []strin
 	if err != nil {
 		return nil, err
 	}
-	r := sendToPeers(execPeers, proposal)
+	r := sendToPeers(ctx, execPeers, proposal)
 
 	response := make([]*ChainCodesResponse, len(r))
 	for idx, p := range r {
@@ -246,17 +314,27 @@ func (c *FabricClient) QueryInstalledChainCodes(identity Identity, peers []strin
 
 // QueryInstantiatedChainCodes get all chainCodes that are running (instantiated) "inside" particular channel in peer
 func (c *FabricClient) QueryInstantiatedChainCodes(identity Identity, channelId string, peers []string) ([]*ChainCodesResponse, error) {
+	return c.QueryInstantiatedChainCodesContext(context.Background(), identity, channelId, peers)
+}
+
+// QueryInstantiatedChainCodesContext is same as QueryInstantiatedChainCodes, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) QueryInstantiatedChainCodesContext(ctx context.Context, identity Identity, channelId string, peers []string) ([]*ChainCodesResponse, error) {
 	execPeers := c.getPeers(peers)
 	if len(peers) != len(execPeers) {
 		return nil, ErrPeerNameNotFound
 	}
 
+	tlsCertHash, err := c.tlsCertHash(execPeers)
+	if err != nil {
+		return nil, err
+	}
 	prop, err := createTransactionProposal(identity, ChainCode{
 		ChannelId: channelId,
 		Name:      LSCC,
 		Type:      ChaincodeSpec_GOLANG,
 		Args:      []string{"getchaincodes"},
-	})
+	}, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -264,7 +342,7 @@ func (c *FabricClient) QueryInstantiatedChainCodes(identity Identity, channelId
 	if err != nil {
 		return nil, err
 	}
-	r := sendToPeers(execPeers, proposal)
+	r := sendToPeers(ctx, execPeers, proposal)
 	response := make([]*ChainCodesResponse, len(r))
 	for idx, p := range r {
 		ic := ChainCodesResponse{PeerName: p.Name, Error: p.Err}
@@ -284,6 +362,12 @@ func (c *FabricClient) QueryInstantiatedChainCodes(identity Identity, channelId
 
 // QueryChannels returns a list of channels that peer/s has joined
 func (c *FabricClient) QueryChannels(identity Identity, peers []string) ([]*QueryChannelsResponse, error) {
+	return c.QueryChannelsContext(context.Background(), identity, peers)
+}
+
+// QueryChannelsContext is same as QueryChannels, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) QueryChannelsContext(ctx context.Context, identity Identity, peers []string) ([]*QueryChannelsResponse, error) {
 	execPeers := c.getPeers(peers)
 	if len(peers) != len(execPeers) {
 		return nil, ErrPeerNameNotFound
@@ -295,7 +379,11 @@ func (c *FabricClient) QueryChannels(identity Identity, peers []string) ([]*Quer
 		Args: []string{"GetChannels"},
 	}
 
-	prop, err := createTransactionProposal(identity, chainCode)
+	tlsCertHash, err := c.tlsCertHash(execPeers)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := createTransactionProposal(identity, chainCode, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -303,7 +391,7 @@ func (c *FabricClient) QueryChannels(identity Identity, peers []string) ([]*Quer
 	if err != nil {
 		return nil, err
 	}
-	r := sendToPeers(execPeers, proposal)
+	r := sendToPeers(ctx, execPeers, proposal)
 	response := make([]*QueryChannelsResponse, 0, len(r))
 	for _, pr := range r {
 		peerResponse := QueryChannelsResponse{PeerName: pr.Name}
@@ -328,6 +416,12 @@ func (c *FabricClient) QueryChannels(identity Identity, peers []string) ([]*Quer
 
 // QueryChannelInfo get current block height, current hash and prev hash about particular channel in peer/s
 func (c *FabricClient) QueryChannelInfo(identity Identity, channelId string, peers []string) ([]*QueryChannelInfoResponse, error) {
+	return c.QueryChannelInfoContext(context.Background(), identity, channelId, peers)
+}
+
+// QueryChannelInfoContext is same as QueryChannelInfo, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) QueryChannelInfoContext(ctx context.Context, identity Identity, channelId string, peers []string) ([]*QueryChannelInfoResponse, error) {
 	execPeers := c.getPeers(peers)
 	if len(peers) != len(execPeers) {
 		return nil, ErrPeerNameNotFound
@@ -339,7 +433,11 @@ func (c *FabricClient) QueryChannelInfo(identity Identity, channelId string, pee
 		Args:      []string{"GetChainInfo", channelId},
 	}
 
-	prop, err := createTransactionProposal(identity, chainCode)
+	tlsCertHash, err := c.tlsCertHash(execPeers)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := createTransactionProposal(identity, chainCode, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -347,7 +445,7 @@ func (c *FabricClient) QueryChannelInfo(identity Identity, channelId string, pee
 	if err != nil {
 		return nil, err
 	}
-	r := sendToPeers(execPeers, proposal)
+	r := sendToPeers(ctx, execPeers, proposal)
 
 	response := make([]*QueryChannelInfoResponse, 0, len(r))
 	for _, pr := range r {
@@ -374,11 +472,21 @@ func (c *FabricClient) QueryChannelInfo(identity Identity, channelId string, pee
 // Because is expected all peers to be in same state this function allows very easy horizontal scaling by
 // distributing query operations between peers.
 func (c *FabricClient) Query(identity Identity, chainCode ChainCode, peers []string) ([]*QueryResponse, error) {
+	return c.QueryContext(context.Background(), identity, chainCode, peers)
+}
+
+// QueryContext is same as Query, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) QueryContext(ctx context.Context, identity Identity, chainCode ChainCode, peers []string) ([]*QueryResponse, error) {
 	execPeers := c.getPeers(peers)
 	if len(peers) != len(execPeers) {
 		return nil, ErrPeerNameNotFound
 	}
-	prop, err := createTransactionProposal(identity, chainCode)
+	tlsCertHash, err := c.tlsCertHash(execPeers)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := createTransactionProposal(identity, chainCode, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -386,7 +494,7 @@ func (c *FabricClient) Query(identity Identity, chainCode ChainCode, peers []str
 	if err != nil {
 		return nil, err
 	}
-	r := sendToPeers(execPeers, proposal)
+	r := sendToPeers(ctx, execPeers, proposal)
 	response := make([]*QueryResponse, len(r))
 	for idx, p := range r {
 		ic := QueryResponse{PeerName: p.Name, Error: p.Err}
@@ -408,40 +516,108 @@ func (c *FabricClient) Query(identity Identity, chainCode ChainCode, peers []str
 // In such case Invoke will return the error and transaction will NOT be send to orderer. This transaction will NOT be
 // committed to blockchain.
 func (c *FabricClient) Invoke(identity Identity, chainCode ChainCode, peers []string, orderer string) (*InvokeResponse, error) {
+	return c.InvokeContext(context.Background(), identity, chainCode, peers, orderer)
+}
+
+// InvokeContext is same as Invoke, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) InvokeContext(ctx context.Context, identity Identity, chainCode ChainCode, peers []string, orderer string) (*InvokeResponse, error) {
+	if _, ok := c.Orderers[orderer]; !ok {
+		return nil, ErrInvalidOrdererName
+	}
+	return c.InvokeWithPolicy(ctx, identity, chainCode, peers, BroadcastPolicy{Orderers: []string{orderer}})
+}
+
+// InvokeWithPolicy is same as InvokeContext, but endorsed transaction is send to orderers according to policy.
+// Policy allows failover between many orderers, retries with backoff and sending to many orderers at once.
+// Every attempt to send the transaction is reported in the response.
+func (c *FabricClient) InvokeWithPolicy(ctx context.Context, identity Identity, chainCode ChainCode, peers []string, policy BroadcastPolicy) (*InvokeResponse, error) {
+	if _, err := c.getOrderers(policy.Orderers); err != nil {
+		return nil, err
+	}
+	transaction, err := c.endorse(ctx, identity, chainCode, peers)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.Broadcast(ctx, transaction.envelope, policy)
+	if err != nil {
+		return nil, err
+	}
+	response := transaction.invokeResponse()
+	response.Status = reply.Response.Status
+	response.Orderer = reply.Orderer
+	response.Attempts = reply.Attempts
+	return response, nil
+}
+
+// InvokeAsync is same as InvokeContext, but it does not wait for orderer response.
+// Endorsement is done before InvokeAsync returns, so simulation errors are returned directly. Endorsed transaction is
+// send over long lived broadcast stream shared by all async invocations to this orderer, so single goroutine can keep
+// many transactions in flight. Orderer acknowledgement is available from returned future.
+func (c *FabricClient) InvokeAsync(ctx context.Context, identity Identity, chainCode ChainCode, peers []string, orderer string) (*InvokeFuture, error) {
 	ord, ok := c.Orderers[orderer]
 	if !ok {
 		return nil, ErrInvalidOrdererName
 	}
+	transaction, err := c.endorse(ctx, identity, chainCode, peers)
+	if err != nil {
+		return nil, err
+	}
+	future, err := ord.BroadcastAsync(ctx, transaction.envelope)
+	if err != nil {
+		return nil, err
+	}
+	return &InvokeFuture{TxID: transaction.txId, Orderer: orderer, broadcast: future,
+		simulation: transaction.invokeResponse()}, nil
+}
+
+// endorse sends chainCode proposal to peers for endorsement and returns signed transaction envelope ready to be send
+// to orderer together with transaction id and peer responses.
+func (c *FabricClient) endorse(ctx context.Context, identity Identity, chainCode ChainCode, peers []string) (*endorsedTransaction, error) {
 	execPeers := c.getPeers(peers)
 	if len(peers) != len(execPeers) {
 		return nil, ErrPeerNameNotFound
 	}
-	prop, err := createTransactionProposal(identity, chainCode)
+	tlsCertHash, err := c.tlsCertHash(execPeers)
 	if err != nil {
 		return nil, err
 	}
-	proposal, err := signedProposal(prop.proposal, identity, c.Crypto)
+	prop, err := createTransactionProposal(identity, chainCode, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
-	transaction, err := createTransaction(prop.proposal, sendToPeers(execPeers, proposal))
+	proposal, err := signedProposal(prop.proposal, identity, c.Crypto)
 	if err != nil {
 		return nil, err
 	}
-	signedTransaction, err := c.Crypto.Sign(transaction, identity.PrivateKey)
+	responses := sendToPeers(ctx, execPeers, proposal)
+	if err := c.verifyEndorsements(prop.proposal, responses); err != nil {
+		return nil, err
+	}
+	transaction, err := createTransaction(prop.proposal, responses)
 	if err != nil {
 		return nil, err
 	}
-	reply, err := ord.Broadcast(&common.Envelope{Payload: transaction, Signature: signedTransaction})
+	signedTransaction, err := c.Crypto.Sign(transaction, identity.PrivateKey)
 	if err != nil {
 		return nil, err
 	}
-	return &InvokeResponse{Status: reply.Status, TxID: prop.transactionId}, nil
+	return &endorsedTransaction{
+		envelope:  &common.Envelope{Payload: transaction, Signature: signedTransaction},
+		txId:      prop.transactionId,
+		responses: responses,
+	}, nil
 }
 
 // QueryTransaction get data for particular transaction.
-// TODO for now it only returns status of the transaction, and not the whole data (payload, endorsement etc)
+// Every peer response contains fully decoded transaction together with its validation code.
 func (c *FabricClient) QueryTransaction(identity Identity, channelId string, txId string, peers []string) ([]*QueryTransactionResponse, error) {
+	return c.QueryTransactionContext(context.Background(), identity, channelId, txId, peers)
+}
+
+// QueryTransactionContext is same as QueryTransaction, but uses ctx for dialing and for every request.
+// Peers that do not respond before ctx is done are reported with ctx error in their response.
+func (c *FabricClient) QueryTransactionContext(ctx context.Context, identity Identity, channelId string, txId string, peers []string) ([]*QueryTransactionResponse, error) {
 	execPeers := c.getPeers(peers)
 	if len(peers) != len(execPeers) {
 		return nil, ErrPeerNameNotFound
@@ -452,7 +628,11 @@ func (c *FabricClient) QueryTransaction(identity Identity, channelId string, txI
 		Type:      ChaincodeSpec_GOLANG,
 		Args:      []string{"GetTransactionByID", channelId, txId}}
 
-	prop, err := createTransactionProposal(identity, chainCode)
+	tlsCertHash, err := c.tlsCertHash(execPeers)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := createTransactionProposal(identity, chainCode, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -460,19 +640,22 @@ func (c *FabricClient) QueryTransaction(identity Identity, channelId string, txI
 	if err != nil {
 		return nil, err
 	}
-	r := sendToPeers(execPeers, proposal)
-	fmt.Println(r)
+	r := sendToPeers(ctx, execPeers, proposal)
 	response := make([]*QueryTransactionResponse, len(r))
 	for idx, p := range r {
 		qtr := QueryTransactionResponse{PeerName: p.Name, Error: p.Err}
 		if p.Err != nil {
 			qtr.Error = p.Err
+		} else if p.Response.Response.GetStatus() != 200 {
+			qtr.Error = fmt.Errorf("%s: %s", ErrBadTransactionStatus, p.Response.Response.GetMessage())
 		} else {
-			dec, err := decodeTransaction(p.Response.Response.GetPayload())
+			dec, err := decodeProcessedTransaction(p.Response.Response.GetPayload())
 			if err != nil {
 				qtr.Error = err
+			} else {
+				qtr.Transaction = dec
+				qtr.StatusCode = int32(dec.ValidationCode)
 			}
-			qtr.StatusCode = dec
 		}
 		response[idx] = &qtr
 	}
@@ -489,6 +672,9 @@ func (c *FabricClient) QueryTransaction(identity Identity, channelId string, txI
 // To cancel listening provide context with cancellation option and call cancel.
 // User can listen for same events in same channel in multiple peers for redundancy using same `chan<- EventBlockResponse`
 // In this case every peer will send its events, so identical events may appear more than once in channel.
+// Response channel is never closed. When ctx is done nothing more is send, listener is stopped and its connection is
+// closed. Use `NewEventListener` directly to control buffering and shutdown.
+// Use `client.NewEventService` to get every block exactly once from multiple peers.
 func (c *FabricClient) ListenForFullBlock(ctx context.Context, identity Identity, eventPeer, channelId string, response chan<- EventBlockResponse) (error) {
 	ep, ok := c.EventPeers[eventPeer]
 	if !ok {
@@ -500,12 +686,28 @@ func (c *FabricClient) ListenForFullBlock(ctx context.Context, identity Identity
 	}
 	err = listener.SeekNewest()
 	if err != nil {
+		listener.Close()
 		return err
 	}
-	listener.Listen(response)
+	forwardEvents(listener, response)
 	return nil
 }
 
+// forwardEvents listens and forwards responses without closing response, so it can be shared by many listeners.
+func forwardEvents(listener *EventListener, response chan<- EventBlockResponse) {
+	ch := make(chan EventBlockResponse)
+	listener.Listen(ch)
+	go func() {
+		for r := range ch {
+			select {
+			case response <- r:
+			case <-listener.Context.Done():
+				listener.Stop()
+			}
+		}
+	}()
+}
+
 // ListenForFilteredBlock listen for events in blockchain. Difference with `ListenForFullBlock` is that event names
 // will be returned but NOT events data. Also full block data will not be available.
 // Other options are same as `ListenForFullBlock`.
@@ -520,29 +722,63 @@ func (c *FabricClient) ListenForFilteredBlock(ctx context.Context, identity Iden
 	}
 	err = listener.SeekNewest()
 	if err != nil {
+		listener.Close()
 		return err
 	}
-	listener.Listen(response)
+	forwardEvents(listener, response)
 	return nil
 }
 
 
+// Close stops commit notifiers and chaincode event registrations and closes all connections to peers, event peers and orderers. Operations started after Close will fail.
+// If more than one connection fails to close, only first error is returned.
+func (c *FabricClient) Close() error {
+	c.notifiersMutex.Lock()
+	for _, n := range c.notifiers {
+		n.Close()
+	}
+	c.notifiers = nil
+	c.notifiersMutex.Unlock()
+
+	c.eventHubsMutex.Lock()
+	hubs := c.eventHubs
+	c.eventHubs = nil
+	c.eventHubsMutex.Unlock()
+	for _, h := range hubs {
+		h.close()
+	}
+
+	var result error
+	for _, p := range c.Peers {
+		if err := p.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	for _, p := range c.EventPeers {
+		if err := p.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	for _, o := range c.Orderers {
+		if err := o.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
 // NewFabricClientFromConfig create a new FabricClient from ClientConfig
 func NewFabricClientFromConfig(config ClientConfig) (*FabricClient, error) {
-	var crypto CryptoSuite
-	var err error
-	switch config.CryptoConfig.Family {
-	case "ecdsa":
-		crypto, err = NewECCryptSuiteFromConfig(config.CryptoConfig)
-		if err != nil {
-			return nil, err
-		}
-	default:
-		return nil, ErrInvalidAlgorithmFamily
+	crypto, err := NewCryptoSuiteFromConfig(config.CryptoConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	peers := make(map[string]*Peer)
 	for name, p := range config.Peers {
+		if p.TlsClientCert == "" && p.TlsClientKey == "" {
+			p.TlsClientCert, p.TlsClientKey = config.ClientTLS.Cert, config.ClientTLS.Key
+		}
 		newPeer, err := NewPeerFromConfig(p)
 		if err != nil {
 			return nil, err
@@ -554,6 +790,9 @@ func NewFabricClientFromConfig(config ClientConfig) (*FabricClient, error) {
 
 	eventPeers := make(map[string]*Peer)
 	for name, p := range config.EventPeers {
+		if p.TlsClientCert == "" && p.TlsClientKey == "" {
+			p.TlsClientCert, p.TlsClientKey = config.ClientTLS.Cert, config.ClientTLS.Key
+		}
 		newEventPeer, err := NewPeerFromConfig(p)
 		if err != nil {
 			return nil, err
@@ -564,6 +803,9 @@ func NewFabricClientFromConfig(config ClientConfig) (*FabricClient, error) {
 
 	orderers := make(map[string]*Orderer)
 	for name, o := range config.Orderers {
+		if o.TlsClientCert == "" && o.TlsClientKey == "" {
+			o.TlsClientCert, o.TlsClientKey = config.ClientTLS.Cert, config.ClientTLS.Key
+		}
 		newOrderer, err := NewOrdererFromConfig(o)
 		if err != nil {
 			return nil, err
@@ -584,7 +826,7 @@ func NewFabricClient(path string) (*FabricClient, error) {
 	return NewFabricClientFromConfig(*config)
 }
 
-func (c FabricClient) getPeers(names []string) []*Peer {
+func (c *FabricClient) getPeers(names []string) []*Peer {
 	res := make([]*Peer, 0, len(names))
 	for _, p := range names {
 		if fp, ok := c.Peers[p]; ok {
@@ -594,7 +836,7 @@ func (c FabricClient) getPeers(names []string) []*Peer {
 	return res
 }
 
-func (c FabricClient) getEventPeers(names []string) []*Peer {
+func (c *FabricClient) getEventPeers(names []string) []*Peer {
 	res := make([]*Peer, 0, len(names))
 	for _, p := range names {
 		if fp, ok := c.EventPeers[p]; ok {
@@ -603,3 +845,19 @@ func (c FabricClient) getEventPeers(names []string) []*Peer {
 	}
 	return res
 }
+
+// tlsCertHash returns TLS client certificate hash that must be included in proposals send to peers.
+// Proposal is signed once and send to all peers, so all peers that use mutual TLS must share same client certificate.
+func (c *FabricClient) tlsCertHash(peers []*Peer) ([]byte, error) {
+	var hash []byte
+	for _, p := range peers {
+		if len(p.TlsCertHash) == 0 {
+			continue
+		}
+		if hash != nil && !bytes.Equal(hash, p.TlsCertHash) {
+			return nil, ErrTLSCertHashMismatch
+		}
+		hash = p.TlsCertHash
+	}
+	return hash, nil
+}
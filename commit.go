@@ -0,0 +1,191 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyperledger/fabric/protos/peer"
+	"time"
+)
+
+// defaultCommitTimeout is used when CommitOptions.Timeout is not set
+const defaultCommitTimeout = 30 * time.Second
+
+// CommitOptions define how `client.InvokeAndCommit` waits for transaction commit.
+type CommitOptions struct {
+	// Timeout is the maximum time to wait for commit after orderer accepts the transaction. Default is 30 seconds.
+	Timeout time.Duration
+	// EventPeers is the list of event peer names used to observe commits. All of them must be joined to the channel.
+	// If empty all configured event peers are used.
+	EventPeers []string
+	// Retry define how transactions invalidated because of read conflict are retried. By default they are not.
+	Retry ConflictRetryPolicy
+}
+
+// ConflictRetryPolicy define retry of transactions invalidated with MVCC_READ_CONFLICT or PHANTOM_READ_CONFLICT.
+// Chaincode is simulated again with new transaction id, so it reads latest state, and new transaction is submitted.
+type ConflictRetryPolicy struct {
+	// Retries is maximum number of retries. Default 0 means transaction is not retried.
+	Retries int
+	// BackoffPolicy is the delay between retries. Random jitter of up to half of the delay is subtracted, so
+	// concurrent conflicting transactions are not retried at the same time.
+	BackoffPolicy
+}
+
+// CommitAttempt is the outcome of single transaction submitted by `client.InvokeAndCommit`.
+type CommitAttempt struct {
+	TxID           string
+	BlockNumber    uint64
+	ValidationCode peer.TxValidationCode
+}
+
+// ConflictRetryError is returned when retry of conflicting transaction fails before its commit is known.
+// Commits holds all previous transactions, all of them are invalid.
+type ConflictRetryError struct {
+	Commits []*CommitAttempt
+	Err     error
+}
+
+func (e *ConflictRetryError) Error() string {
+	return fmt.Sprintf("retry after %d conflicting transactions failed: %v", len(e.Commits), e.Err)
+}
+
+// Unwrap returns error from the retry
+func (e *ConflictRetryError) Unwrap() error {
+	return e.Err
+}
+
+// CommitResponse is the result of `client.InvokeAndCommit`.
+// Transaction is committed in block BlockNumber, but it changes the ledger only when ValidationCode is VALID.
+type CommitResponse struct {
+	InvokeResponse
+	// BlockNumber is the number of the block where transaction is committed
+	BlockNumber uint64
+	// ValidationCode is the result of transaction validation. Use `Valid()` to check is transaction valid.
+	ValidationCode peer.TxValidationCode
+	// Events are chaincode events emitted by the transaction. Only event names are available.
+	Events []EventBlockResponseTransactionEvent
+	// EventPeer is the name of event peer that reported the commit
+	EventPeer string
+	// Commits holds every transaction submitted when conflicting transactions are retried, last one is this
+	// transaction.
+	Commits []*CommitAttempt
+}
+
+// Valid reports whether committed transaction is valid and its changes are applied to the ledger.
+func (r *CommitResponse) Valid() bool {
+	return r.ValidationCode == peer.TxValidationCode_VALID
+}
+
+// CommitError is returned when transaction is accepted by orderer, but its commit was not observed.
+// This does not mean that transaction failed, it may be committed later. TxID can be used to check its status.
+type CommitError struct {
+	TxID string
+	// Err is ErrCommitTimeout or the error from event stream
+	Err error
+}
+
+func (e *CommitError) Error() string {
+	return fmt.Sprintf("commit of transaction %s is unknown: %v", e.TxID, e.Err)
+}
+
+// InvokeAndCommit is same as InvokeWithPolicy, but it also waits until transaction is committed in the ledger.
+// Before transaction is send to orderer, its id is registered in commit notifier that listens for blocks on event
+// peers. Notifier is shared with `client.CommitNotifier` and all InvokeAndCommit calls in the same channel and it is
+// started on first call, using identity from that call.
+// When transaction is committed CommitResponse is returned with validation code and block number. Note that committed
+// transaction may be invalid (for example MVCC_READ_CONFLICT), so always check `response.Valid()`.
+// Transactions invalidated because of read conflict are retried according to `options.Retry`.
+// If commit is not observed before timeout or ctx is done, *CommitError is returned. In this case transaction may
+// still be committed. If notifier does not receive its first block before timeout, transaction is not send and
+// ErrCommitTimeout is returned.
+func (c *FabricClient) InvokeAndCommit(ctx context.Context, identity Identity, chainCode ChainCode, peers []string,
+	policy BroadcastPolicy, options CommitOptions) (*CommitResponse, error) {
+	if _, err := c.getOrderers(policy.Orderers); err != nil {
+		return nil, err
+	}
+	notifier, err := c.CommitNotifier(identity, chainCode.ChannelId, CommitNotifierOptions{EventPeers: options.EventPeers})
+	if err != nil {
+		return nil, err
+	}
+	var commits []*CommitAttempt
+	backoff := options.Retry.newBackoff()
+	for retry := 0; ; retry++ {
+		response, err := c.invokeAndWait(ctx, notifier, identity, chainCode, peers, policy, options.Timeout)
+		if err != nil {
+			if len(commits) > 0 {
+				return nil, &ConflictRetryError{Commits: commits, Err: err}
+			}
+			return nil, err
+		}
+		commits = append(commits, &CommitAttempt{TxID: response.TxID, BlockNumber: response.BlockNumber,
+			ValidationCode: response.ValidationCode})
+		response.Commits = commits
+		if !readConflict(response.ValidationCode) || retry >= options.Retry.Retries {
+			return response, nil
+		}
+		select {
+		case <-time.After(jitter(backoff.next())):
+		case <-ctx.Done():
+			return response, nil
+		}
+	}
+}
+
+func readConflict(code peer.TxValidationCode) bool {
+	return code == peer.TxValidationCode_MVCC_READ_CONFLICT || code == peer.TxValidationCode_PHANTOM_READ_CONFLICT
+}
+
+// invokeAndWait endorses and submits single transaction and waits for its commit.
+func (c *FabricClient) invokeAndWait(ctx context.Context, notifier *CommitNotifier, identity Identity,
+	chainCode ChainCode, peers []string, policy BroadcastPolicy, timeout time.Duration) (*CommitResponse, error) {
+	transaction, err := c.endorse(ctx, identity, chainCode, peers)
+	if err != nil {
+		return nil, err
+	}
+	txId := transaction.txId
+	if timeout <= 0 {
+		timeout = defaultCommitTimeout
+	}
+	committed := notifier.Register(txId, timeout)
+	defer committed.Cancel()
+	select {
+	case <-committed.Done():
+		// notifier failed or did not receive first block in time, transaction is not send
+		if _, err := committed.Result(); err != nil {
+			return nil, err
+		}
+	default:
+	}
+
+	reply, err := c.Broadcast(ctx, transaction.envelope, policy)
+	if err != nil {
+		return nil, err
+	}
+	response := &CommitResponse{InvokeResponse: *transaction.invokeResponse()}
+	response.Status = reply.Response.Status
+	response.Orderer = reply.Orderer
+	response.Attempts = reply.Attempts
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-committed.Done():
+		commit, err := committed.Result()
+		if err != nil {
+			return nil, &CommitError{TxID: txId, Err: err}
+		}
+		response.BlockNumber = commit.BlockNumber
+		response.ValidationCode = commit.ValidationCode
+		response.Events = commit.Events
+		response.EventPeer = commit.EventPeer
+		return response, nil
+	case <-timer.C:
+		return nil, &CommitError{TxID: txId, Err: ErrCommitTimeout}
+	case <-ctx.Done():
+		return nil, &CommitError{TxID: txId, Err: ctx.Err()}
+	}
+}
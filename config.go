@@ -12,11 +12,19 @@ import (
 // ClientConfig holds config data for crypto, peers and orderers
 type ClientConfig struct {
 	CryptoConfig                        `yaml:"crypto"`
+	ClientTLS  ClientTLSConfig          `yaml:"clientTLS"`
 	Orderers   map[string]OrdererConfig `yaml:"orderers"`
 	Peers      map[string]PeerConfig    `yaml:"peers"`
 	EventPeers map[string]PeerConfig    `yaml:"eventPeers"`
 }
 
+// ClientTLSConfig holds client certificate and key used for mutual TLS with every peer and orderer
+// that does not provide its own client certificate.
+type ClientTLSConfig struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
 // CAConfig holds config for Fabric CA
 type CAConfig struct {
 	CryptoConfig             `yaml:"crypto"`
@@ -26,24 +34,43 @@ type CAConfig struct {
 }
 
 // Config holds config values for fabric and fabric-ca cryptography
+// Library, Slot, Label and Pin are used only by pkcs11 family. Token is selected by Label, or by Slot if Label is empty.
 type CryptoConfig struct {
 	Family    string `yaml:"family"`
 	Algorithm string `yaml:"algorithm"`
 	Hash      string `yaml:"hash"`
+	Library   string `yaml:"library"`
+	Slot      uint   `yaml:"slot"`
+	Label     string `yaml:"label"`
+	Pin       string `yaml:"pin"`
 }
 
 // PeerConfig hold config values for Peer. ULR is in address:port notation
+// TlsClientCert and TlsClientKey are needed only when peer requires mutual TLS (clientAuthRequired).
+// PoolSize is the number of gRPC connections to this peer, default is 1.
+// MspId is the MSP of peer organization, it is needed to select endorsers from endorsement policy.
 type PeerConfig struct {
-	Host    string `yaml:"host"`
-	UseTLS  bool   `yaml:"useTLS"`
-	TlsPath string `yaml:"tlsPath"`
+	Host          string `yaml:"host"`
+	MspId         string `yaml:"mspId"`
+	UseTLS        bool   `yaml:"useTLS"`
+	TlsPath       string `yaml:"tlsPath"`
+	TlsClientCert string `yaml:"tlsClientCert"`
+	TlsClientKey  string `yaml:"tlsClientKey"`
+	PoolSize      int    `yaml:"poolSize"`
 }
 
 // OrdererConfig hold config values for Orderer. ULR is in address:port notation
+// TlsClientCert and TlsClientKey are needed only when orderer requires mutual TLS (clientAuthRequired).
+// PoolSize is the number of gRPC connections to this orderer, default is 1.
+// VerifyBlocks enables hash verification of blocks delivered from this orderer.
 type OrdererConfig struct {
-	Host    string `yaml:"host"`
-	UseTLS  bool   `yaml:"useTLS"`
-	TlsPath string `yaml:"tlsPath"`
+	Host          string `yaml:"host"`
+	UseTLS        bool   `yaml:"useTLS"`
+	TlsPath       string `yaml:"tlsPath"`
+	TlsClientCert string `yaml:"tlsClientCert"`
+	TlsClientKey  string `yaml:"tlsClientKey"`
+	PoolSize      int    `yaml:"poolSize"`
+	VerifyBlocks  bool   `yaml:"verifyBlocks"`
 }
 
 // NewFabricClientConfig create config from provided yaml file in path
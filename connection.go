@@ -0,0 +1,123 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"sync"
+	"sync/atomic"
+)
+
+// poolsMutex guards lazy creation of connection pools for peers and orderers created manually, without constructor.
+var poolsMutex sync.Mutex
+
+// connectionPool holds fixed number of gRPC connections to single peer or orderer.
+// Connections are created lazily on first use and are used in round-robin order.
+// connectionPool is safe for concurrent use.
+type connectionPool struct {
+	uri   string
+	opts  []grpc.DialOption
+	next  uint64
+	slots []*pooledConnection
+}
+
+type pooledConnection struct {
+	mutex sync.Mutex
+	conn  *grpc.ClientConn
+	// dialing is closed when dial of this connection finishes, it is nil when nobody is dialing
+	dialing chan struct{}
+	closed  bool
+}
+
+func newConnectionPool(uri string, opts []grpc.DialOption, size int) *connectionPool {
+	if size < 1 {
+		size = 1
+	}
+	slots := make([]*pooledConnection, size)
+	for i := range slots {
+		slots[i] = new(pooledConnection)
+	}
+	return &connectionPool{uri: uri, opts: opts, slots: slots}
+}
+
+// lazyConnectionPool returns pool stored in pool and creates new one if pool is nil.
+func lazyConnectionPool(pool **connectionPool, uri string, opts []grpc.DialOption, size int) *connectionPool {
+	poolsMutex.Lock()
+	defer poolsMutex.Unlock()
+	if *pool == nil {
+		*pool = newConnectionPool(uri, opts, size)
+	}
+	return *pool
+}
+
+// get returns next connection from the pool. If connection is not created yet or it is shut down, new connection is
+// dialed using ctx. Connection in TransientFailure is kept, gRPC reconnects it by itself and it may still be used by
+// other goroutines. Only one goroutine dials particular connection at a time, others wait for it until their own ctx
+// is done. Slot is not locked while dialing, so slow dial never blocks callers with shorter ctx.
+func (p *connectionPool) get(ctx context.Context) (*grpc.ClientConn, error) {
+	idx := (atomic.AddUint64(&p.next, 1) - 1) % uint64(len(p.slots))
+	slot := p.slots[idx]
+	for {
+		slot.mutex.Lock()
+		if slot.closed {
+			slot.mutex.Unlock()
+			return nil, ErrConnectionClosed
+		}
+		if slot.conn != nil && slot.conn.GetState() != connectivity.Shutdown {
+			conn := slot.conn
+			slot.mutex.Unlock()
+			return conn, nil
+		}
+		if slot.dialing == nil {
+			break
+		}
+		dialing := slot.dialing
+		slot.mutex.Unlock()
+		select {
+		case <-dialing:
+			// dial finished, it may have failed, so check the slot again
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	dialing := make(chan struct{})
+	slot.dialing = dialing
+	slot.mutex.Unlock()
+
+	conn, err := grpc.DialContext(ctx, p.uri, p.opts...)
+
+	slot.mutex.Lock()
+	defer slot.mutex.Unlock()
+	slot.dialing = nil
+	close(dialing)
+	if err != nil {
+		return nil, err
+	}
+	if slot.closed {
+		conn.Close()
+		return nil, ErrConnectionClosed
+	}
+	slot.conn = conn
+	return conn, nil
+}
+
+// close closes all connections in the pool. Pool cannot be used after close.
+func (p *connectionPool) close() error {
+	var result error
+	for _, slot := range p.slots {
+		slot.mutex.Lock()
+		if slot.conn != nil {
+			if err := slot.conn.Close(); err != nil && result == nil {
+				result = err
+			}
+			slot.conn = nil
+		}
+		slot.closed = true
+		slot.mutex.Unlock()
+	}
+	return result
+}
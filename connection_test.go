@@ -0,0 +1,77 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// stuckDialer returns dial option which dialer blocks until release is closed
+func stuckDialer(release <-chan struct{}) grpc.DialOption {
+	return grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+		<-release
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: context.Canceled}
+	})
+}
+
+func TestConnectionPoolGetDoesNotWaitForStuckDial(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	pool := newConnectionPool("stuck.example.com:7051", []grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock(),
+		stuckDialer(release)}, 1)
+
+	started := make(chan struct{})
+	go func() {
+		close(started)
+		pool.get(context.Background())
+	}()
+	<-started
+	// wait until first caller owns the dial
+	deadline := time.Now().Add(time.Second)
+	for {
+		pool.slots[0].mutex.Lock()
+		dialing := pool.slots[0].dialing != nil
+		pool.slots[0].mutex.Unlock()
+		if dialing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first dial did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := pool.get(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("get returned after %v, it must not wait for other dial", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.get(cancelled); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestConnectionPoolClosed(t *testing.T) {
+	pool := newConnectionPool("closed.example.com:7051", []grpc.DialOption{grpc.WithInsecure()}, 2)
+	if err := pool.close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if _, err := pool.get(context.Background()); err != ErrConnectionClosed {
+		t.Fatalf("expected ErrConnectionClosed, got %v", err)
+	}
+}
@@ -5,6 +5,7 @@ License: Apache License Version 2.0
 package gohfc
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -28,7 +29,8 @@ type CryptoSuite interface {
 	GenerateKey() (interface{}, error)
 	// CreateCertificateRequest will create CSR request. It takes enrolmentId and Private key
 	CreateCertificateRequest(enrollmentId string, key interface{}, hosts []string) ([]byte, error)
-	// Sign signs message. It takes message to sign and Private key
+	// Sign signs message. It takes message to sign and Private key. Key can be any crypto.Signer, for example
+	// client of remote signing service.
 	Sign(msg []byte, key interface{}) ([]byte, error)
 	// Hash computes Hash value of provided data. Hash function will be different in different crypto implementations.
 	Hash(data []byte) []byte
@@ -50,6 +52,8 @@ type ECCryptSuite struct {
 	sigAlgorithm x509.SignatureAlgorithm
 	key          *ecdsa.PrivateKey
 	hashFunction func() hash.Hash
+	// hashOpts identifies hashFunction for crypto.Signer implementations
+	hashOpts crypto.Hash
 }
 
 type eCDSASignature struct {
@@ -109,31 +113,53 @@ func (c *ECCryptSuite) CreateCertificateRequest(enrollmentId string, key interfa
 }
 
 func (c *ECCryptSuite) Sign(msg []byte, k interface{}) ([]byte, error) {
-	key, ok := k.(*ecdsa.PrivateKey)
+	switch key := k.(type) {
+	case *ecdsa.PrivateKey:
+		var h []byte
+		h = c.Hash(msg)
+		R, S, err := ecdsa.Sign(rand.Reader, key, h)
+		if err != nil {
+			return nil, err
+		}
+		preventMalleability(&key.PublicKey, S)
+		sig, err := asn1.Marshal(eCDSASignature{R, S})
+		if err != nil {
+			return nil, err
+		}
+		return sig, nil
+	case crypto.Signer:
+		return c.signWithSigner(msg, key)
+	default:
+		return nil, ErrInvalidKeyType
+	}
+}
+
+// signWithSigner signs hash of the message using crypto.Signer. Signer must use ECDSA key and return ASN.1 DER
+// signature. Signature is converted to low S, because signer may not know about this Fabric requirement.
+func (c *ECCryptSuite) signWithSigner(msg []byte, signer crypto.Signer) ([]byte, error) {
+	pub, ok := signer.Public().(*ecdsa.PublicKey)
 	if !ok {
 		return nil, ErrInvalidKeyType
 	}
-	var h []byte
-	h = c.Hash(msg)
-	R, S, err := ecdsa.Sign(rand.Reader, key, h)
+	raw, err := signer.Sign(rand.Reader, c.Hash(msg), c.hashOpts)
 	if err != nil {
 		return nil, err
 	}
-	c.preventMalleability(key, S)
-	sig, err := asn1.Marshal(eCDSASignature{R, S})
-	if err != nil {
-		return nil, err
+	sig := new(eCDSASignature)
+	if _, err := asn1.Unmarshal(raw, sig); err != nil {
+		return nil, ErrInvalidSignature
 	}
-	return sig, nil
+	preventMalleability(pub, sig.S)
+	return asn1.Marshal(*sig)
 }
 
 // ECDSA signature can be "exploited" using symmetry of S values.
 // Fabric (by convention) accepts only signatures with lowS values
 // If result of a signature is high-S value we have to subtract S from curve.N
 // For more details https://github.com/bitcoin/bips/blob/master/bip-0062.mediawiki
-func (c *ECCryptSuite) preventMalleability(k *ecdsa.PrivateKey, S *big.Int) {
-	halfOrder := ecCurveHalfOrders[k.Curve]
-	if S.Cmp(halfOrder) == 1 {
+func preventMalleability(k *ecdsa.PublicKey, S *big.Int) {
+	halfOrder, ok := ecCurveHalfOrders[k.Curve]
+	if ok && S.Cmp(halfOrder) == 1 {
 		S.Sub(k.Params().N, S)
 	}
 }
@@ -144,6 +170,19 @@ func (c *ECCryptSuite) Hash(data []byte) []byte {
 	return h.Sum(nil)
 }
 
+// NewCryptoSuiteFromConfig creates crypto suite for config family. Supported families are `ecdsa` and `pkcs11`.
+// pkcs11 is available only when gohfc is build with `pkcs11` build tag.
+func NewCryptoSuiteFromConfig(config CryptoConfig) (CryptoSuite, error) {
+	switch config.Family {
+	case "ecdsa":
+		return NewECCryptSuiteFromConfig(config)
+	case "pkcs11":
+		return NewPKCS11CryptSuiteFromConfig(config)
+	default:
+		return nil, ErrInvalidAlgorithmFamily
+	}
+}
+
 // NewECCryptSuite creates new Elliptic curve crypto suite from config
 func NewECCryptSuiteFromConfig(config CryptoConfig) (CryptoSuite, error) {
 	var suite *ECCryptSuite
@@ -162,12 +201,16 @@ func NewECCryptSuiteFromConfig(config CryptoConfig) (CryptoSuite, error) {
 
 	case "SHA2-256":
 		suite.hashFunction = sha256.New
+		suite.hashOpts = crypto.SHA256
 	case "SHA2-384":
 		suite.hashFunction = sha512.New384
+		suite.hashOpts = crypto.SHA384
 	case "SHA3-256":
 		suite.hashFunction = sha3.New256
+		suite.hashOpts = crypto.SHA3_256
 	case "SHA3-384":
 		suite.hashFunction = sha3.New384
+		suite.hashOpts = crypto.SHA3_384
 	default:
 		return nil, ErrInvalidHash
 	}
@@ -0,0 +1,257 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protos/peer"
+	"time"
+)
+
+// Transaction is decoded transaction from the ledger.
+// Only header fields are set for transactions that are not endorser transactions (for example config updates).
+type Transaction struct {
+	Type      common.HeaderType
+	ChannelId string
+	TxID      string
+	Timestamp time.Time
+	Epoch     uint64
+	// Creator is identity that created and signed the transaction
+	Creator *TransactionIdentity
+	// ValidationCode is the result of transaction validation
+	ValidationCode peer.TxValidationCode
+	// Actions are chaincode invocations in this transaction. Transactions created by Fabric SDKs have one action.
+	Actions []*TransactionAction
+}
+
+// ChannelHeader is decoded header common to all envelopes in the channel
+type ChannelHeader struct {
+	Type        common.HeaderType
+	Version     int32
+	ChannelId   string
+	TxID        string
+	Timestamp   time.Time
+	Epoch       uint64
+	TlsCertHash []byte
+}
+
+// SignatureHeader is decoded header with creator of the envelope
+type SignatureHeader struct {
+	Creator *TransactionIdentity
+	Nonce   []byte
+}
+
+// TransactionIdentity is identity of transaction creator or endorser.
+// Certificate is nil if identity is not x509 identity (for example idemix).
+type TransactionIdentity struct {
+	MspId       string
+	Certificate *x509.Certificate
+}
+
+// TransactionAction is single chaincode invocation with its endorsed result.
+type TransactionAction struct {
+	ChainCodeName    string
+	ChainCodeVersion string
+	ChainCodeType    peer.ChaincodeSpec_Type
+	// Function is the first argument of the invocation and Args are the rest. Transient map is never part of the
+	// transaction.
+	Function string
+	Args     [][]byte
+	// Response is the chaincode response returned during simulation
+	Response *peer.Response
+	// Endorsers are identities of peers that endorsed the transaction
+	Endorsers []*TransactionIdentity
+	// ReadWriteSets are reads and writes done by chaincode in every namespace
+	ReadWriteSets []*NamespaceReadWriteSet
+	// Event is chaincode event set during simulation or nil
+	Event *peer.ChaincodeEvent
+}
+
+// decodeProcessedTransaction decodes result of QSCC GetTransactionByID
+func decodeProcessedTransaction(payload []byte) (*Transaction, error) {
+	processed := new(peer.ProcessedTransaction)
+	if err := proto.Unmarshal(payload, processed); err != nil {
+		return nil, err
+	}
+	if processed.TransactionEnvelope == nil {
+		return nil, ErrInvalidTransaction
+	}
+	transaction, err := decodeEnvelope(processed.TransactionEnvelope)
+	if err != nil {
+		return nil, err
+	}
+	transaction.ValidationCode = peer.TxValidationCode(processed.ValidationCode)
+	return transaction, nil
+}
+
+// decodeEnvelope decodes transaction envelope. Validation code is not part of envelope, so it is not set.
+func decodeEnvelope(envelope *common.Envelope) (*Transaction, error) {
+	payload, channelHeader, signatureHeader, err := decodePayload(envelope.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return newTransaction(channelHeader, signatureHeader, payload.Data)
+}
+
+// decodePayload decodes envelope payload and both its headers
+func decodePayload(data []byte) (*common.Payload, *ChannelHeader, *SignatureHeader, error) {
+	payload := new(common.Payload)
+	if err := proto.Unmarshal(data, payload); err != nil {
+		return nil, nil, nil, err
+	}
+	if payload.Header == nil {
+		return nil, nil, nil, ErrInvalidTransaction
+	}
+	ch := new(common.ChannelHeader)
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, ch); err != nil {
+		return nil, nil, nil, err
+	}
+	channelHeader := &ChannelHeader{
+		Type:        common.HeaderType(ch.Type),
+		Version:     ch.Version,
+		ChannelId:   ch.ChannelId,
+		TxID:        ch.TxId,
+		Epoch:       ch.Epoch,
+		TlsCertHash: ch.TlsCertHash,
+	}
+	if ch.Timestamp != nil {
+		timestamp, err := ptypes.Timestamp(ch.Timestamp)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		channelHeader.Timestamp = timestamp
+	}
+	signatureHeader, err := decodeSignatureHeader(payload.Header.SignatureHeader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return payload, channelHeader, signatureHeader, nil
+}
+
+func decodeSignatureHeader(data []byte) (*SignatureHeader, error) {
+	sh := new(common.SignatureHeader)
+	if err := proto.Unmarshal(data, sh); err != nil {
+		return nil, err
+	}
+	creator, err := decodeIdentity(sh.Creator)
+	if err != nil {
+		return nil, err
+	}
+	return &SignatureHeader{Creator: creator, Nonce: sh.Nonce}, nil
+}
+
+// newTransaction creates transaction from decoded headers. Actions are decoded only for endorser transactions.
+func newTransaction(channelHeader *ChannelHeader, signatureHeader *SignatureHeader, data []byte) (*Transaction, error) {
+	transaction := &Transaction{
+		Type:      channelHeader.Type,
+		ChannelId: channelHeader.ChannelId,
+		TxID:      channelHeader.TxID,
+		Timestamp: channelHeader.Timestamp,
+		Epoch:     channelHeader.Epoch,
+		Creator:   signatureHeader.Creator,
+	}
+	if transaction.Type != common.HeaderType_ENDORSER_TRANSACTION {
+		return transaction, nil
+	}
+
+	tx := new(peer.Transaction)
+	if err := proto.Unmarshal(data, tx); err != nil {
+		return nil, err
+	}
+	for _, a := range tx.Actions {
+		action, err := decodeTransactionAction(a)
+		if err != nil {
+			return nil, err
+		}
+		transaction.Actions = append(transaction.Actions, action)
+	}
+	return transaction, nil
+}
+
+func decodeTransactionAction(a *peer.TransactionAction) (*TransactionAction, error) {
+	actionPayload := new(peer.ChaincodeActionPayload)
+	if err := proto.Unmarshal(a.Payload, actionPayload); err != nil {
+		return nil, err
+	}
+	result := new(TransactionAction)
+
+	proposalPayload := new(peer.ChaincodeProposalPayload)
+	if err := proto.Unmarshal(actionPayload.ChaincodeProposalPayload, proposalPayload); err != nil {
+		return nil, err
+	}
+	invocation := new(peer.ChaincodeInvocationSpec)
+	if err := proto.Unmarshal(proposalPayload.Input, invocation); err != nil {
+		return nil, err
+	}
+	if spec := invocation.ChaincodeSpec; spec != nil {
+		result.ChainCodeType = spec.Type
+		result.ChainCodeName = spec.GetChaincodeId().GetName()
+		result.ChainCodeVersion = spec.GetChaincodeId().GetVersion()
+		if args := spec.GetInput().GetArgs(); len(args) > 0 {
+			result.Function = string(args[0])
+			result.Args = args[1:]
+		}
+	}
+
+	if actionPayload.Action == nil {
+		return result, nil
+	}
+	for _, e := range actionPayload.Action.Endorsements {
+		endorser, err := decodeIdentity(e.Endorser)
+		if err != nil {
+			return nil, err
+		}
+		result.Endorsers = append(result.Endorsers, endorser)
+	}
+	responsePayload := new(peer.ProposalResponsePayload)
+	if err := proto.Unmarshal(actionPayload.Action.ProposalResponsePayload, responsePayload); err != nil {
+		return nil, err
+	}
+	chaincodeAction := new(peer.ChaincodeAction)
+	if err := proto.Unmarshal(responsePayload.Extension, chaincodeAction); err != nil {
+		return nil, err
+	}
+	result.Response = chaincodeAction.Response
+	// committed version is the version that endorsed the transaction
+	if id := chaincodeAction.ChaincodeId; id != nil {
+		result.ChainCodeName = id.Name
+		result.ChainCodeVersion = id.Version
+	}
+	if len(chaincodeAction.Events) > 0 {
+		event := new(peer.ChaincodeEvent)
+		if err := proto.Unmarshal(chaincodeAction.Events, event); err != nil {
+			return nil, err
+		}
+		result.Event = event
+	}
+	if len(chaincodeAction.Results) > 0 {
+		sets, err := DecodeReadWriteSet(chaincodeAction.Results)
+		if err != nil {
+			return nil, err
+		}
+		result.ReadWriteSets = sets.Namespaces
+	}
+	return result, nil
+}
+
+// decodeIdentity decodes serialized identity. Certificate is parsed only if identity is PEM encoded x509 certificate.
+func decodeIdentity(data []byte) (*TransactionIdentity, error) {
+	serialized := new(msp.SerializedIdentity)
+	if err := proto.Unmarshal(data, serialized); err != nil {
+		return nil, err
+	}
+	identity := &TransactionIdentity{MspId: serialized.Mspid}
+	if block, _ := pem.Decode(serialized.IdBytes); block != nil {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			identity.Certificate = cert
+		}
+	}
+	return identity, nil
+}
@@ -0,0 +1,590 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protos/peer"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EndorsementPolicy is used to select endorsers for the transaction and to check collected endorsements before
+// transaction is send to orderer.
+// Policy principals are matched against `Peer.MspId` when selecting peers and against endorser identity when checking
+// endorsements. Roles (member, peer, admin, client) cannot be checked on client side, so only MSP id is compared.
+// If Policy is nil, proposal is send to all Peers and MinEndorsements matching endorsements are required.
+type EndorsementPolicy struct {
+	// Policy is the chaincode endorsement policy. Use `client.ChainCodePolicy` to get it from the ledger.
+	Policy *common.SignaturePolicyEnvelope
+	// Peers is the list of candidate endorsers. If empty all configured peers are candidates.
+	Peers []string
+	// MinEndorsements is used only when Policy is nil. Default is the number of Peers, or majority of Peers in Quorum
+	// mode.
+	MinEndorsements int
+	// Tolerant allows transaction to go ahead when some peers fail, return non 200 status, fail verification or
+	// return different result, as long as remaining matching endorsements are enough. Dropped peers are reported
+	// in `InvokeResponse.Endorsements`.
+	Tolerant bool
+	// Quorum sends proposal to all candidate peers at once and returns as soon as matching endorsements satisfy Policy
+	// (or MinEndorsements when Policy is nil). Calls that are still in flight are cancelled and reported as
+	// stragglers in `InvokeResponse.Endorsements`. Quorum mode is always tolerant. To use quorum with plain list of
+	// peers set Peers and leave Policy nil.
+	Quorum bool
+}
+
+// InsufficientEndorsementsError is returned in tolerant mode when endorsements that are left after dropping failed
+// peers are not enough.
+type InsufficientEndorsementsError struct {
+	// Endorsements holds response from every peer with the reason why it is dropped
+	Endorsements []*EndorsementSummary
+}
+
+func (e *InsufficientEndorsementsError) Error() string {
+	reasons := make([]string, 0, len(e.Endorsements))
+	for _, s := range e.Endorsements {
+		if !s.Included {
+			reasons = append(reasons, fmt.Sprintf("%s: %v", s.Peer, s.Reason))
+		}
+	}
+	return fmt.Sprintf("%s, dropped peers: %s", ErrEndorsementPolicyNotSatisfied, strings.Join(reasons, "; "))
+}
+
+// Unwrap allows errors.Is(err, ErrEndorsementPolicyNotSatisfied)
+func (e *InsufficientEndorsementsError) Unwrap() error {
+	return ErrEndorsementPolicyNotSatisfied
+}
+
+// satisfiedBy checks are endorsements enough to send transaction to orderer
+func (e EndorsementPolicy) satisfiedBy(responses []*PeerResponse) bool {
+	if e.Policy != nil {
+		return satisfiesPolicy(e.Policy, endorsers(responses))
+	}
+	min := e.MinEndorsements
+	if min <= 0 {
+		min = len(e.Peers)
+		if e.Quorum {
+			min = len(e.Peers)/2 + 1
+		}
+	}
+	return len(responses) > 0 && len(responses) >= min
+}
+
+// chaincodeData is the data stored by LSCC for every instantiated chaincode. Fields are same as in Fabric
+// `ccprovider.ChaincodeData`, only Policy is used.
+type chaincodeData struct {
+	Name                string `protobuf:"bytes,1,opt,name=name"`
+	Version             string `protobuf:"bytes,2,opt,name=version"`
+	Escc                string `protobuf:"bytes,3,opt,name=escc"`
+	Vscc                string `protobuf:"bytes,4,opt,name=vscc"`
+	Policy              []byte `protobuf:"bytes,5,opt,name=policy"`
+	Data                []byte `protobuf:"bytes,6,opt,name=data"`
+	Id                  []byte `protobuf:"bytes,7,opt,name=id"`
+	InstantiationPolicy []byte `protobuf:"bytes,8,opt,name=instantiation_policy"`
+}
+
+func (cd *chaincodeData) Reset()         { *cd = chaincodeData{} }
+func (cd *chaincodeData) String() string { return proto.CompactTextString(cd) }
+func (*chaincodeData) ProtoMessage()     {}
+
+// endorserSelection is the result of peer selection. Selected peers are send the proposal first, fallback peers are
+// used when selected peer from same MSP fails.
+type endorserSelection struct {
+	selected []*Peer
+	fallback map[string][]*Peer
+}
+
+// ChainCodePolicy returns endorsement policy of instantiated chaincode using LSCC `getccdata`.
+// First peer that returns the policy is used.
+func (c *FabricClient) ChainCodePolicy(ctx context.Context, identity Identity, channelId string, chainCodeName string, peers []string) (*common.SignaturePolicyEnvelope, error) {
+	chainCode := ChainCode{
+		ChannelId: channelId,
+		Name:      LSCC,
+		Type:      ChaincodeSpec_GOLANG,
+		Args:      []string{"getccdata", channelId, chainCodeName},
+	}
+	responses, err := c.QueryContext(ctx, identity, chainCode, peers)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error = ErrNoValidEndorsementFound
+	for _, r := range responses {
+		if r.Error != nil {
+			lastErr = r.Error
+			continue
+		}
+		if r.Response.Response.Status != 200 {
+			lastErr = ErrBadTransactionStatus
+			continue
+		}
+		data := new(chaincodeData)
+		if err := proto.Unmarshal(r.Response.Response.Payload, data); err != nil {
+			lastErr = err
+			continue
+		}
+		policy := new(common.SignaturePolicyEnvelope)
+		if err := proto.Unmarshal(data.Policy, policy); err != nil {
+			lastErr = err
+			continue
+		}
+		return policy, nil
+	}
+	return nil, lastErr
+}
+
+// SelectEndorsers returns names of the smallest set of candidate peers that can satisfy the policy.
+// If policy is nil all candidate peers are returned.
+// ErrEndorsementPolicyUnsatisfiable is returned if all candidates together cannot satisfy it.
+func (c *FabricClient) SelectEndorsers(policy EndorsementPolicy) ([]string, error) {
+	selection, err := c.selectEndorsers(policy)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(selection.selected))
+	for _, p := range selection.selected {
+		names = append(names, p.Name)
+	}
+	return names, nil
+}
+
+// InvokeWithEndorsementPolicy is same as InvokeWithPolicy, but endorsers are selected by the client from endorsement
+// policy. If selected peer fails, other peer from same MSP is tried. Collected endorsements are checked against the
+// policy and transaction is not send to orderer if policy is not satisfied.
+// In tolerant mode failed peers are dropped instead of failing whole invoke, and *InsufficientEndorsementsError is
+// returned only when remaining endorsements are not enough.
+func (c *FabricClient) InvokeWithEndorsementPolicy(ctx context.Context, identity Identity, chainCode ChainCode, endorsement EndorsementPolicy, policy BroadcastPolicy) (*InvokeResponse, error) {
+	if _, err := c.getOrderers(policy.Orderers); err != nil {
+		return nil, err
+	}
+	transaction, err := c.endorseWithPolicy(ctx, identity, chainCode, endorsement)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.Broadcast(ctx, transaction.envelope, policy)
+	if err != nil {
+		return nil, err
+	}
+	response := transaction.invokeResponse()
+	response.Status = reply.Response.Status
+	response.Orderer = reply.Orderer
+	response.Attempts = reply.Attempts
+	return response, nil
+}
+
+func (c *FabricClient) endorseWithPolicy(ctx context.Context, identity Identity, chainCode ChainCode, endorsement EndorsementPolicy) (*endorsedTransaction, error) {
+	selection, err := c.selectEndorsers(endorsement)
+	if err != nil {
+		return nil, err
+	}
+	all := append([]*Peer{}, selection.selected...)
+	for _, peers := range selection.fallback {
+		all = append(all, peers...)
+	}
+	tlsCertHash, err := c.tlsCertHash(all)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := createTransactionProposal(identity, chainCode, tlsCertHash)
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := signedProposal(prop.proposal, identity, c.Crypto)
+	if err != nil {
+		return nil, err
+	}
+	result := &endorsedTransaction{txId: prop.transactionId, dropped: make(map[*PeerResponse]error)}
+	if endorsement.Quorum {
+		var endorsed []*PeerResponse
+		endorsed, result.responses = c.sendQuorum(ctx, all, proposal, prop.proposal, endorsement, result.dropped)
+		if endorsed == nil {
+			return nil, &InsufficientEndorsementsError{Endorsements: result.invokeResponse().Endorsements}
+		}
+		return c.signTransaction(identity, prop.proposal, endorsed, result)
+	}
+
+	endorsed, failed := sendWithFallback(ctx, selection, proposal)
+	result.responses = append(append([]*PeerResponse{}, endorsed...), failed...)
+	for _, r := range failed {
+		result.dropped[r] = responseError(r)
+	}
+	if endorsement.Tolerant {
+		endorsed = c.keepMatching(prop.proposal, endorsed, endorsement, result.dropped)
+		if endorsed == nil {
+			return nil, &InsufficientEndorsementsError{Endorsements: result.invokeResponse().Endorsements}
+		}
+	} else {
+		if endorsement.Policy == nil && len(failed) > 0 {
+			return nil, result.dropped[failed[0]]
+		}
+		if err := c.verifyEndorsements(prop.proposal, endorsed); err != nil {
+			return nil, err
+		}
+		if !endorsement.satisfiedBy(endorsed) {
+			if len(failed) > 0 && failed[0].Err != nil {
+				return nil, failed[0].Err
+			}
+			return nil, ErrEndorsementPolicyNotSatisfied
+		}
+	}
+	return c.signTransaction(identity, prop.proposal, endorsed, result)
+}
+
+// signTransaction creates transaction from endorsements and signs it
+func (c *FabricClient) signTransaction(identity Identity, proposal []byte, endorsed []*PeerResponse, result *endorsedTransaction) (*endorsedTransaction, error) {
+	transaction, err := createTransaction(proposal, endorsed)
+	if err != nil {
+		return nil, err
+	}
+	signedTransaction, err := c.Crypto.Sign(transaction, identity.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	result.envelope = &common.Envelope{Payload: transaction, Signature: signedTransaction}
+	return result, nil
+}
+
+// sendQuorum sends proposal to all peers and returns as soon as group of matching endorsements is enough. Calls that
+// are still in flight are cancelled and returned as responses with ErrEndorsementCancelled. Returns kept endorsements,
+// or nil if there are not enough, and responses from all peers.
+func (c *FabricClient) sendQuorum(ctx context.Context, peers []*Peer, prop *peer.SignedProposal, proposal []byte, endorsement EndorsementPolicy, dropped map[*PeerResponse]error) ([]*PeerResponse, []*PeerResponse) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// buffered, so cancelled calls can finish after we return
+	ch := make(chan *PeerResponse, len(peers))
+	pending := make(map[string]bool, len(peers))
+	for _, p := range peers {
+		pending[p.Name] = true
+		go p.EndorseContext(ctx, ch, prop)
+	}
+	var responses []*PeerResponse
+	var groups [][]*PeerResponse
+	kept := -1
+	for len(pending) > 0 && kept < 0 {
+		r := <-ch
+		delete(pending, r.Name)
+		responses = append(responses, r)
+		if err := responseError(r); err != nil {
+			dropped[r] = err
+			continue
+		}
+		if err := c.verifyEndorsements(proposal, []*PeerResponse{r}); err != nil {
+			dropped[r] = err
+			continue
+		}
+		idx := -1
+		for i, g := range groups {
+			if bytes.Equal(g[0].Response.Payload, r.Response.Payload) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			groups = append(groups, nil)
+			idx = len(groups) - 1
+		}
+		groups[idx] = append(groups[idx], r)
+		if endorsement.satisfiedBy(groups[idx]) {
+			kept = idx
+		}
+	}
+	for _, p := range peers {
+		if pending[p.Name] {
+			r := &PeerResponse{Name: p.Name, Err: ErrEndorsementCancelled}
+			dropped[r] = ErrEndorsementCancelled
+			responses = append(responses, r)
+		}
+	}
+	for i, g := range groups {
+		if i != kept {
+			for _, r := range g {
+				dropped[r] = ErrEndorsementsDoNotMatch
+			}
+		}
+	}
+	if kept < 0 {
+		return nil, responses
+	}
+	return groups[kept], responses
+}
+
+// keepMatching drops endorsements that fail verification and keeps the largest group of matching endorsements that
+// satisfies the policy. Returns nil if there is no such group. Reason for every dropped endorsement is saved.
+func (c *FabricClient) keepMatching(proposal []byte, endorsed []*PeerResponse, endorsement EndorsementPolicy, dropped map[*PeerResponse]error) []*PeerResponse {
+	var groups [][]*PeerResponse
+	for _, r := range endorsed {
+		if err := c.verifyEndorsements(proposal, []*PeerResponse{r}); err != nil {
+			dropped[r] = err
+			continue
+		}
+		found := false
+		for i, g := range groups {
+			if bytes.Equal(g[0].Response.Payload, r.Response.Payload) {
+				groups[i] = append(g, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []*PeerResponse{r})
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return len(groups[i]) > len(groups[j]) })
+	var kept []*PeerResponse
+	for _, g := range groups {
+		if kept == nil && endorsement.satisfiedBy(g) {
+			kept = g
+			continue
+		}
+		for _, r := range g {
+			dropped[r] = ErrEndorsementsDoNotMatch
+		}
+	}
+	return kept
+}
+
+// responseError returns the reason why peer response cannot be used as endorsement
+func responseError(r *PeerResponse) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	if r.Response.GetResponse().GetStatus() != 200 {
+		return ErrBadTransactionStatus
+	}
+	return nil
+}
+
+// selectEndorsers finds the smallest set of peers that satisfies the policy. Every peer is represented only by its
+// MSP id, so only number of peers from every MSP matters. Peers from same MSP are sorted by name, first ones are
+// selected and others are kept for fallback.
+func (c *FabricClient) selectEndorsers(policy EndorsementPolicy) (*endorserSelection, error) {
+	if policy.Policy == nil && len(policy.Peers) > 0 {
+		selected := c.getPeers(policy.Peers)
+		if len(selected) != len(policy.Peers) {
+			return nil, ErrPeerNameNotFound
+		}
+		return &endorserSelection{selected: selected}, nil
+	}
+	if policy.Policy == nil || policy.Policy.Rule == nil {
+		return nil, ErrEndorsementPolicyMissing
+	}
+	var candidates []*Peer
+	if len(policy.Peers) == 0 {
+		for _, p := range c.Peers {
+			candidates = append(candidates, p)
+		}
+	} else {
+		candidates = c.getPeers(policy.Peers)
+		if len(candidates) != len(policy.Peers) {
+			return nil, ErrPeerNameNotFound
+		}
+	}
+	byMsp := make(map[string][]*Peer)
+	for _, p := range candidates {
+		if p.MspId != "" {
+			byMsp[p.MspId] = append(byMsp[p.MspId], p)
+		}
+	}
+	for _, peers := range byMsp {
+		sort.Slice(peers, func(i, j int) bool { return peers[i].Name < peers[j].Name })
+	}
+
+	// single peer can sign only once, so MSP never needs more peers than there are signatures in the policy
+	leaves := countSignedBy(policy.Policy.Rule)
+	var slots []string
+	for mspId, peers := range byMsp {
+		n := len(peers)
+		if n > leaves {
+			n = leaves
+		}
+		for i := 0; i < n; i++ {
+			slots = append(slots, mspId)
+		}
+	}
+	sort.Strings(slots)
+
+	for size := 1; size <= len(slots); size++ {
+		if msps := smallestCombination(policy.Policy, slots, size); msps != nil {
+			selection := &endorserSelection{fallback: make(map[string][]*Peer)}
+			used := make(map[string]int)
+			for _, mspId := range msps {
+				selection.selected = append(selection.selected, byMsp[mspId][used[mspId]])
+				used[mspId]++
+			}
+			for mspId, peers := range byMsp {
+				if used[mspId] > 0 && used[mspId] < len(peers) {
+					selection.fallback[mspId] = peers[used[mspId]:]
+				}
+			}
+			return selection, nil
+		}
+	}
+	return nil, ErrEndorsementPolicyUnsatisfiable
+}
+
+// smallestCombination returns first combination of size slots that satisfies the policy.
+func smallestCombination(policy *common.SignaturePolicyEnvelope, slots []string, size int) []string {
+	idx := make([]int, size)
+	for i := range idx {
+		idx[i] = i
+	}
+	identities := make([]*msp.SerializedIdentity, size)
+	for {
+		for i, s := range idx {
+			identities[i] = &msp.SerializedIdentity{Mspid: slots[s]}
+		}
+		if satisfiesPolicy(policy, identities) {
+			result := make([]string, size)
+			for i, s := range idx {
+				result[i] = slots[s]
+			}
+			return result
+		}
+		// next combination
+		i := size - 1
+		for i >= 0 && idx[i] == len(slots)-size+i {
+			i--
+		}
+		if i < 0 {
+			return nil
+		}
+		idx[i]++
+		for j := i + 1; j < size; j++ {
+			idx[j] = idx[j-1] + 1
+		}
+	}
+}
+
+func countSignedBy(rule *common.SignaturePolicy) int {
+	switch t := rule.Type.(type) {
+	case *common.SignaturePolicy_SignedBy:
+		return 1
+	case *common.SignaturePolicy_NOutOf_:
+		count := 0
+		for _, r := range t.NOutOf.Rules {
+			count += countSignedBy(r)
+		}
+		return count
+	}
+	return 0
+}
+
+// sendWithFallback sends proposal to all selected peers. When peer fails, proposal is send to next fallback peer from
+// same MSP until one succeeds or there are no more peers. Returns successful responses and all failed ones.
+func sendWithFallback(ctx context.Context, selection *endorserSelection, prop *peer.SignedProposal) ([]*PeerResponse, []*PeerResponse) {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	var endorsed, failed []*PeerResponse
+	fallback := make(map[string][]*Peer)
+	for mspId, peers := range selection.fallback {
+		fallback[mspId] = append([]*Peer{}, peers...)
+	}
+	for _, p := range selection.selected {
+		wg.Add(1)
+		go func(p *Peer) {
+			defer wg.Done()
+			ch := make(chan *PeerResponse, 1)
+			for p != nil {
+				p.EndorseContext(ctx, ch, prop)
+				r := <-ch
+				mutex.Lock()
+				if r.Err == nil && r.Response.Response.Status == 200 {
+					endorsed = append(endorsed, r)
+					mutex.Unlock()
+					return
+				}
+				failed = append(failed, r)
+				mspId := p.MspId
+				p = nil
+				if next := fallback[mspId]; len(next) > 0 && ctx.Err() == nil {
+					p = next[0]
+					fallback[mspId] = next[1:]
+				}
+				mutex.Unlock()
+			}
+		}(p)
+	}
+	wg.Wait()
+	return endorsed, failed
+}
+
+// endorsers returns identities of peers that signed the endorsements.
+func endorsers(responses []*PeerResponse) []*msp.SerializedIdentity {
+	identities := make([]*msp.SerializedIdentity, 0, len(responses))
+	for _, r := range responses {
+		id := new(msp.SerializedIdentity)
+		if err := proto.Unmarshal(r.Response.GetEndorsement().GetEndorser(), id); err != nil {
+			continue
+		}
+		identities = append(identities, id)
+	}
+	return identities
+}
+
+// satisfiesPolicy evaluates signature policy same way as Fabric does. Every identity can be used only once.
+func satisfiesPolicy(policy *common.SignaturePolicyEnvelope, identities []*msp.SerializedIdentity) bool {
+	if policy == nil || policy.Rule == nil {
+		return false
+	}
+	return evaluateRule(policy.Rule, policy.Identities, identities, make([]bool, len(identities)))
+}
+
+func evaluateRule(rule *common.SignaturePolicy, principals []*msp.MSPPrincipal, identities []*msp.SerializedIdentity, used []bool) bool {
+	switch t := rule.Type.(type) {
+	case *common.SignaturePolicy_SignedBy:
+		if t.SignedBy < 0 || int(t.SignedBy) >= len(principals) {
+			return false
+		}
+		for i, id := range identities {
+			if !used[i] && principalMatches(principals[t.SignedBy], id) {
+				used[i] = true
+				return true
+			}
+		}
+		return false
+	case *common.SignaturePolicy_NOutOf_:
+		verified := int32(0)
+		tmp := make([]bool, len(used))
+		for _, r := range t.NOutOf.Rules {
+			copy(tmp, used)
+			if evaluateRule(r, principals, identities, tmp) {
+				verified++
+				copy(used, tmp)
+			}
+		}
+		return verified >= t.NOutOf.N
+	}
+	return false
+}
+
+// principalMatches checks is identity from principal MSP. For IDENTITY principals whole identity must match.
+func principalMatches(principal *msp.MSPPrincipal, identity *msp.SerializedIdentity) bool {
+	switch principal.PrincipalClassification {
+	case msp.MSPPrincipal_ROLE:
+		role := new(msp.MSPRole)
+		if err := proto.Unmarshal(principal.Principal, role); err != nil {
+			return false
+		}
+		return role.MspIdentifier == identity.Mspid
+	case msp.MSPPrincipal_ORGANIZATION_UNIT:
+		ou := new(msp.OrganizationUnit)
+		if err := proto.Unmarshal(principal.Principal, ou); err != nil {
+			return false
+		}
+		return ou.MspIdentifier == identity.Mspid
+	case msp.MSPPrincipal_IDENTITY:
+		id := new(msp.SerializedIdentity)
+		if err := proto.Unmarshal(principal.Principal, id); err != nil {
+			return false
+		}
+		return id.Mspid == identity.Mspid && bytes.Equal(id.IdBytes, identity.IdBytes)
+	}
+	return false
+}
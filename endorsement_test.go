@@ -0,0 +1,226 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
+)
+
+func signedBy(principal int32) *common.SignaturePolicy {
+	return &common.SignaturePolicy{Type: &common.SignaturePolicy_SignedBy{SignedBy: principal}}
+}
+
+func nOutOf(n int32, rules ...*common.SignaturePolicy) *common.SignaturePolicy {
+	return &common.SignaturePolicy{Type: &common.SignaturePolicy_NOutOf_{
+		NOutOf: &common.SignaturePolicy_NOutOf{N: n, Rules: rules}}}
+}
+
+// testPolicy creates policy with member principal for every MSP, principal index is the index in mspIds
+func testPolicy(t *testing.T, rule *common.SignaturePolicy, mspIds ...string) *common.SignaturePolicyEnvelope {
+	policy := &common.SignaturePolicyEnvelope{Rule: rule}
+	for _, mspId := range mspIds {
+		role, err := proto.Marshal(&msp.MSPRole{MspIdentifier: mspId, Role: msp.MSPRole_MEMBER})
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy.Identities = append(policy.Identities,
+			&msp.MSPPrincipal{PrincipalClassification: msp.MSPPrincipal_ROLE, Principal: role})
+	}
+	return policy
+}
+
+func testIdentities(mspIds ...string) []*msp.SerializedIdentity {
+	identities := make([]*msp.SerializedIdentity, 0, len(mspIds))
+	for _, mspId := range mspIds {
+		identities = append(identities, &msp.SerializedIdentity{Mspid: mspId})
+	}
+	return identities
+}
+
+func TestSatisfiesPolicy(t *testing.T) {
+	and := nOutOf(2, signedBy(0), signedBy(1))
+	or := nOutOf(1, signedBy(0), signedBy(1))
+	twoOfThree := nOutOf(2, signedBy(0), signedBy(1), signedBy(2))
+	// Org1 and (Org2 or Org3)
+	nested := nOutOf(2, signedBy(0), nOutOf(1, signedBy(1), signedBy(2)))
+	// two signatures of Org1
+	twice := nOutOf(2, signedBy(0), signedBy(0))
+
+	tests := []struct {
+		name       string
+		rule       *common.SignaturePolicy
+		identities []string
+		expected   bool
+	}{
+		{"AND both", and, []string{"Org1MSP", "Org2MSP"}, true},
+		{"AND one", and, []string{"Org1MSP"}, false},
+		{"AND wrong", and, []string{"Org1MSP", "Org3MSP"}, false},
+		{"OR first", or, []string{"Org1MSP"}, true},
+		{"OR second", or, []string{"Org2MSP"}, true},
+		{"OR none", or, []string{"Org3MSP"}, false},
+		{"2-of-3 two", twoOfThree, []string{"Org3MSP", "Org1MSP"}, true},
+		{"2-of-3 all", twoOfThree, []string{"Org1MSP", "Org2MSP", "Org3MSP"}, true},
+		{"2-of-3 one", twoOfThree, []string{"Org2MSP"}, false},
+		{"2-of-3 same org", twoOfThree, []string{"Org2MSP", "Org2MSP"}, false},
+		{"nested left and right", nested, []string{"Org1MSP", "Org3MSP"}, true},
+		{"nested right only", nested, []string{"Org2MSP", "Org3MSP"}, false},
+		{"nested left only", nested, []string{"Org1MSP"}, false},
+		{"identity used once", twice, []string{"Org1MSP"}, false},
+		{"identity used once two", twice, []string{"Org1MSP", "Org1MSP"}, true},
+		{"missing mspId", or, []string{""}, false},
+		{"no identities", or, nil, false},
+	}
+	for _, test := range tests {
+		policy := testPolicy(t, test.rule, "Org1MSP", "Org2MSP", "Org3MSP")
+		if got := satisfiesPolicy(policy, testIdentities(test.identities...)); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+
+	if satisfiesPolicy(nil, testIdentities("Org1MSP")) {
+		t.Error("nil policy must not be satisfied")
+	}
+	if satisfiesPolicy(testPolicy(t, signedBy(3), "Org1MSP"), testIdentities("Org1MSP")) {
+		t.Error("principal out of range must not be satisfied")
+	}
+}
+
+func TestEvaluateRuleDoesNotConsumeIdentitiesOfFailedRule(t *testing.T) {
+	// first rule fails after using Org1 identity, it must still be available for second rule
+	rule := nOutOf(1, nOutOf(2, signedBy(0), signedBy(1)), signedBy(0))
+	policy := testPolicy(t, rule, "Org1MSP", "Org2MSP")
+	identities := testIdentities("Org1MSP")
+	used := make([]bool, len(identities))
+	if !evaluateRule(policy.Rule, policy.Identities, identities, used) {
+		t.Fatal("expected rule to be satisfied")
+	}
+	if !used[0] {
+		t.Fatal("expected identity to be used by satisfied rule")
+	}
+}
+
+func TestSelectEndorsers(t *testing.T) {
+	client := &FabricClient{Peers: map[string]*Peer{
+		"peer0.org1": {Name: "peer0.org1", MspId: "Org1MSP"},
+		"peer1.org1": {Name: "peer1.org1", MspId: "Org1MSP"},
+		"peer0.org2": {Name: "peer0.org2", MspId: "Org2MSP"},
+		"peer0.org3": {Name: "peer0.org3", MspId: "Org3MSP"},
+		"nomsp":      {Name: "nomsp"},
+	}}
+	tests := []struct {
+		name     string
+		rule     *common.SignaturePolicy
+		peers    []string
+		selected []string
+		fallback map[string][]string
+		err      error
+	}{
+		{
+			name:     "AND",
+			rule:     nOutOf(2, signedBy(0), signedBy(1)),
+			selected: []string{"peer0.org1", "peer0.org2"},
+			fallback: map[string][]string{"Org1MSP": {"peer1.org1"}},
+		},
+		{
+			name:     "OR",
+			rule:     nOutOf(1, signedBy(1), signedBy(2)),
+			selected: []string{"peer0.org2"},
+			fallback: map[string][]string{},
+		},
+		{
+			name:     "2-of-3",
+			rule:     nOutOf(2, signedBy(0), signedBy(1), signedBy(2)),
+			selected: []string{"peer0.org1", "peer0.org2"},
+			fallback: map[string][]string{"Org1MSP": {"peer1.org1"}},
+		},
+		{
+			name:     "nested",
+			rule:     nOutOf(2, signedBy(2), nOutOf(1, signedBy(0), signedBy(1))),
+			selected: []string{"peer0.org1", "peer0.org3"},
+			fallback: map[string][]string{"Org1MSP": {"peer1.org1"}},
+		},
+		{
+			name:     "two peers of same MSP",
+			rule:     nOutOf(2, signedBy(0), signedBy(0)),
+			selected: []string{"peer0.org1", "peer1.org1"},
+			fallback: map[string][]string{},
+		},
+		{
+			name:     "candidates",
+			rule:     nOutOf(1, signedBy(0), signedBy(1)),
+			peers:    []string{"peer0.org2", "nomsp"},
+			selected: []string{"peer0.org2"},
+			fallback: map[string][]string{},
+		},
+		{
+			name:  "missing mspId",
+			rule:  signedBy(0),
+			peers: []string{"nomsp"},
+			err:   ErrEndorsementPolicyUnsatisfiable,
+		},
+		{
+			name: "unknown MSP",
+			rule: nOutOf(2, signedBy(0), signedBy(3)),
+			err:  ErrEndorsementPolicyUnsatisfiable,
+		},
+		{
+			name:  "unknown peer",
+			rule:  signedBy(0),
+			peers: []string{"peer9.org1"},
+			err:   ErrPeerNameNotFound,
+		},
+	}
+	for _, test := range tests {
+		policy := EndorsementPolicy{
+			Policy: testPolicy(t, test.rule, "Org1MSP", "Org2MSP", "Org3MSP", "Org4MSP"),
+			Peers:  test.peers,
+		}
+		selection, err := client.selectEndorsers(policy)
+		if err != test.err {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var selected []string
+		for _, p := range selection.selected {
+			selected = append(selected, p.Name)
+		}
+		sort.Strings(selected)
+		if !reflect.DeepEqual(selected, test.selected) {
+			t.Errorf("%s: expected selected %v, got %v", test.name, test.selected, selected)
+		}
+		fallback := make(map[string][]string)
+		for mspId, peers := range selection.fallback {
+			for _, p := range peers {
+				fallback[mspId] = append(fallback[mspId], p.Name)
+			}
+		}
+		if !reflect.DeepEqual(fallback, test.fallback) {
+			t.Errorf("%s: expected fallback %v, got %v", test.name, test.fallback, fallback)
+		}
+	}
+}
+
+func TestSelectEndorsersWithoutPolicy(t *testing.T) {
+	client := &FabricClient{Peers: map[string]*Peer{"peer0.org1": {Name: "peer0.org1"}}}
+	selection, err := client.selectEndorsers(EndorsementPolicy{Peers: []string{"peer0.org1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selection.selected) != 1 || selection.selected[0].Name != "peer0.org1" {
+		t.Fatalf("expected all Peers to be selected, got %v", selection.selected)
+	}
+	if _, err := client.selectEndorsers(EndorsementPolicy{}); err != ErrEndorsementPolicyMissing {
+		t.Fatalf("expected ErrEndorsementPolicyMissing, got %v", err)
+	}
+}
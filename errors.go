@@ -35,4 +35,34 @@ var (
 	ErrAffiliationNameMissing        = errors.New("affiliation must have name")
 	ErrAffiliationNewNameMissing        = errors.New("affiliation must have new name")
 	ErrIdentityNameMissing        = errors.New("identity must have  name")
+	ErrTLSClientKeyPairIncomplete   = errors.New("both TLS client certificate and key must be provided")
+	ErrTLSCertHashMismatch          = errors.New("peers are configured with different TLS client certificates")
+	ErrConnectionClosed             = errors.New("connection is closed")
+	ErrCommitTimeout                = errors.New("transaction commit was not observed before timeout")
+	ErrCommitCancelled              = errors.New("commit registration is cancelled")
+	ErrEventListenerClosed          = errors.New("event listener is closed")
+	ErrEventBufferOverflow          = errors.New("event listener buffer is full")
+	ErrEndorsementPolicyMissing     = errors.New("endorsement policy is missing")
+	ErrEndorsementPolicyUnsatisfiable = errors.New("configured peers cannot satisfy endorsement policy")
+	ErrEndorsementPolicyNotSatisfied  = errors.New("collected endorsements do not satisfy endorsement policy")
+	ErrEndorsementSignatureInvalid  = errors.New("endorsement signature is invalid")
+	ErrProposalHashMismatch         = errors.New("endorsement is not for this proposal")
+	ErrEndorserCertificateInvalid   = errors.New("endorser certificate is not valid")
+	ErrEndorserMSPUnknown           = errors.New("endorser MSP is not known in the channel")
+	ErrInvalidConfigBlock           = errors.New("invalid channel configuration block")
+	ErrEndorsementCancelled         = errors.New("endorsement is cancelled because quorum is reached")
+	ErrInvalidSignature             = errors.New("signature is not valid for the digest and certificate")
+	ErrInvalidTransaction           = errors.New("invalid transaction payload")
+	ErrSignerCertificateMismatch    = errors.New("signer public key does not match certificate")
+	ErrPKCS11NotSupported           = errors.New("pkcs11 support is not compiled, build with pkcs11 tag")
+	ErrPKCS11TokenNotFound          = errors.New("pkcs11 token is not found")
+	ErrPKCS11KeyNotFound            = errors.New("pkcs11 key is not found")
+	ErrInvalidDigestLength          = errors.New("digest length does not match hash function")
+	ErrInvalidCompositeKey          = errors.New("key is not composite key")
+	ErrInvalidCompositeKeyAttribute = errors.New("composite key attribute is not valid utf8 or contains U+0000 or U+10FFFF")
+	ErrInvalidBlock                 = errors.New("invalid block")
+	ErrBlockDataHashMismatch        = errors.New("block data hash does not match block data")
+	ErrBlockNumberGap               = errors.New("block is not next block after last verified block")
+	ErrBlockPreviousHashMismatch    = errors.New("block previous hash does not match last verified block")
+	ErrFilteredBlockNotVerifiable   = errors.New("filtered blocks cannot be verified")
 )
@@ -15,6 +15,8 @@ import (
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/protos/peer"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -22,6 +24,19 @@ const (
 	EventTypeFiltered
 )
 
+// OverflowPolicy define what EventListener does when its buffer is full, because consumer does not read responses
+// fast enough.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops receiving blocks from peer until consumer reads responses. This is the default.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest block in buffer to make room for the new one. Errors are never dropped.
+	OverflowDropOldest
+	// OverflowFail stops the listener immediately, buffered blocks are dropped and `Err` returns ErrEventBufferOverflow.
+	OverflowFail
+)
+
 const (
 	maxRecvMsgSize = 100 * 1024 * 1024
 	maxSendMsgSize = 100 * 1024 * 1024
@@ -46,8 +61,26 @@ type EventListener struct {
 	ChannelId    string
 	ListenerType int
 	FullBlock    bool
-	connection   *grpc.ClientConn
-	client       deliveryClient
+	// BlockVerifier checks data hash and hash chain of every received block when set. Stream is stopped on first
+	// block that cannot be verified. Filtered blocks have no hashes, so it cannot be used with EventTypeFiltered.
+	BlockVerifier *BlockVerifier
+	// Buffer is the number of responses kept while consumer is not reading. Default is 0, so every block waits for
+	// consumer. OverflowDropOldest and OverflowFail need buffer, at least 1 is used with them.
+	Buffer int
+	// Overflow is what happens when buffer is full. Default is OverflowBlock.
+	Overflow   OverflowPolicy
+	connection *grpc.ClientConn
+	client     deliveryClient
+	// ctx is cancelled by Stop, on termination of Listen or when Context is done, and it cancels the stream
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mutex     sync.Mutex
+	listening bool
+	running   sync.WaitGroup
+	done      chan struct{}
+	err       error
+	closeErr  error
+	dropped   uint64
 }
 
 type EventBlockResponse struct {
@@ -56,6 +89,8 @@ type EventBlockResponse struct {
 	BlockHeight  uint64
 	Transactions []EventBlockResponseTransaction
 	RawBlock     []byte
+	// block is received full block, consumers that skip duplicate blocks verify it only when it is delivered
+	block *common.Block
 }
 
 type EventBlockResponseTransaction struct {
@@ -67,15 +102,16 @@ type EventBlockResponseTransaction struct {
 }
 
 type EventBlockResponseTransactionEvent struct {
-	Name  string
-	Value []byte
+	Name        string
+	Value       []byte
+	ChainCodeId string
 }
 
 func (e *EventListener) newConnection() error {
 
 
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(e.ctx, 5*time.Minute)
 	defer cancel()
 	conn, err := grpc.DialContext(ctx, e.Peer.Uri, e.Peer.Opts...)
 	if err != nil {
@@ -84,13 +120,13 @@ func (e *EventListener) newConnection() error {
 	e.connection = conn
 	switch e.ListenerType {
 	case EventTypeFiltered:
-		client, err := peer.NewDeliverClient(e.connection).DeliverFiltered(e.Context)
+		client, err := peer.NewDeliverClient(e.connection).DeliverFiltered(e.ctx)
 		if err != nil {
 			return err
 		}
 		e.client = client
 	case EventTypeFullBlock:
-		client, err := peer.NewDeliverClient(e.connection).Deliver(e.Context)
+		client, err := peer.NewDeliverClient(e.connection).Deliver(e.ctx)
 		if err != nil {
 			return err
 		}
@@ -151,22 +187,192 @@ func (e *EventListener) SeekRange(start, end uint64) error {
 	return e.client.Send(seek)
 }
 
+// Listen receives blocks in new goroutine and sends them to response. Response is closed when listener terminates.
+// If stream fails, error is send as the last response. If listener is stopped or Context is done nothing more is
+// send, use `Err` to get the reason. Listen can be called only once, response of every other call is closed
+// immediately.
 func (e *EventListener) Listen(response chan<- EventBlockResponse) {
-	go func() {
-		for {
-			msg, err := e.client.Recv()
-			if err != nil {
-				response <- EventBlockResponse{Error: fmt.Errorf("error receiving data:%v", err)}
+	e.mutex.Lock()
+	if e.listening || e.ctx.Err() != nil {
+		e.mutex.Unlock()
+		close(response)
+		return
+	}
+	e.listening = true
+	e.running.Add(2)
+	e.mutex.Unlock()
+
+	size := e.Buffer
+	if size < 1 && e.Overflow != OverflowBlock {
+		size = 1
+	}
+	queue := make(chan EventBlockResponse, size)
+	go e.receive(queue)
+	go e.send(queue, response)
+}
+
+// Stop stops the listener without waiting. Use `Done` to know when it is fully shut down.
+func (e *EventListener) Stop() {
+	e.cancel()
+}
+
+// Close stops the listener and waits until its goroutines exit and connection is closed. It returns error from
+// closing the connection.
+func (e *EventListener) Close() error {
+	e.cancel()
+	<-e.done
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.closeErr
+}
+
+// Done returns channel that is closed when listener is fully shut down: goroutines exited, response is closed and
+// connection is closed.
+func (e *EventListener) Done() <-chan struct{} {
+	return e.done
+}
+
+// Err returns the reason listener terminated: stream error, ErrEventBufferOverflow, ErrEventListenerClosed when
+// stopped or Context error. It is nil while listener is running.
+func (e *EventListener) Err() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.err
+}
+
+// Dropped returns the number of blocks dropped with OverflowDropOldest policy
+func (e *EventListener) Dropped() uint64 {
+	return atomic.LoadUint64(&e.dropped)
+}
+
+// receive reads stream and puts responses in queue. Queue is closed when stream fails or listener is stopped.
+func (e *EventListener) receive(queue chan EventBlockResponse) {
+	defer e.running.Done()
+	defer close(queue)
+	for {
+		msg, err := e.client.Recv()
+		if err != nil {
+			if e.ctx.Err() == nil {
+				e.terminate(queue, EventBlockResponse{Error: fmt.Errorf("error receiving data:%v", err)})
+			}
+			return
+		}
+		var response EventBlockResponse
+		switch t := msg.Type.(type) {
+		case *peer.DeliverResponse_Block:
+			if e.BlockVerifier != nil {
+				if err := e.BlockVerifier.Verify(t.Block); err != nil {
+					e.terminate(queue, EventBlockResponse{Error: err, BlockHeight: t.Block.GetHeader().GetNumber()})
+					return
+				}
+			}
+			response = *e.parseFullBlock(t, e.FullBlock)
+		case *peer.DeliverResponse_FilteredBlock:
+			if e.BlockVerifier != nil {
+				e.terminate(queue, EventBlockResponse{Error: ErrFilteredBlockNotVerifiable})
 				return
 			}
-			switch t := msg.Type.(type) {
-			case *peer.DeliverResponse_Block:
-				response <- *e.parseFullBlock(t, e.FullBlock)
-			case *peer.DeliverResponse_FilteredBlock:
-				response <- *e.parseFilteredBlock(t, e.FullBlock)
+			response = *e.parseFilteredBlock(t, e.FullBlock)
+		default:
+			continue
+		}
+		if !e.enqueue(queue, response) {
+			return
+		}
+	}
+}
+
+// enqueue puts response in queue according to overflow policy. It returns false when listener must stop.
+func (e *EventListener) enqueue(queue chan EventBlockResponse, response EventBlockResponse) bool {
+	switch e.Overflow {
+	case OverflowDropOldest:
+		for {
+			select {
+			case queue <- response:
+				return true
+			case <-e.ctx.Done():
+				return false
+			default:
+			}
+			select {
+			case <-queue:
+				atomic.AddUint64(&e.dropped, 1)
+			default:
+			}
+		}
+	case OverflowFail:
+		select {
+		case queue <- response:
+			return true
+		default:
+			// queue is full, so error is send only if there is room meanwhile and listener stops right away
+			e.setErr(ErrEventBufferOverflow)
+			select {
+			case queue <- EventBlockResponse{Error: ErrEventBufferOverflow}:
+			default:
 			}
+			e.cancel()
+			return false
+		}
+	default:
+		select {
+		case queue <- response:
+			return true
+		case <-e.ctx.Done():
+			return false
+		}
+	}
+}
+
+// terminate records error and sends it as the last response, unless listener is stopped meanwhile
+func (e *EventListener) terminate(queue chan EventBlockResponse, response EventBlockResponse) {
+	e.setErr(response.Error)
+	select {
+	case queue <- response:
+	case <-e.ctx.Done():
+	}
+}
+
+// send forwards queue to response. When queue is closed or listener is stopped it closes response and shuts down.
+func (e *EventListener) send(queue chan EventBlockResponse, response chan<- EventBlockResponse) {
+	defer e.running.Done()
+	defer e.cancel()
+	defer close(response)
+	for r := range queue {
+		select {
+		case response <- r:
+		case <-e.ctx.Done():
+			return
 		}
-	}()
+	}
+}
+
+// shutdown waits until listener is stopped, then closes the connection
+func (e *EventListener) shutdown() {
+	<-e.ctx.Done()
+	// Listen checks ctx under mutex, so after this no goroutine can be added to running
+	e.mutex.Lock()
+	e.mutex.Unlock()
+	e.running.Wait()
+	if e.Context.Err() != nil {
+		e.setErr(e.Context.Err())
+	} else {
+		e.setErr(ErrEventListenerClosed)
+	}
+	err := e.connection.Close()
+	e.mutex.Lock()
+	e.closeErr = err
+	e.mutex.Unlock()
+	close(e.done)
+}
+
+// setErr records the first error that terminated the listener
+func (e *EventListener) setErr(err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
 }
 
 func (e *EventListener) parseFilteredBlock(block *peer.DeliverResponse_FilteredBlock, fullBlock bool) (*EventBlockResponse) {
@@ -174,7 +380,7 @@ func (e *EventListener) parseFilteredBlock(block *peer.DeliverResponse_FilteredB
 	response := &EventBlockResponse{
 		ChannelId:    block.FilteredBlock.ChannelId,
 		BlockHeight:  block.FilteredBlock.Number,
-		Transactions: make([]EventBlockResponseTransaction, len(block.FilteredBlock.FilteredTransactions)),
+		Transactions: make([]EventBlockResponseTransaction, 0, len(block.FilteredBlock.FilteredTransactions)),
 	}
 	if fullBlock {
 		m, err := proto.Marshal(block.FilteredBlock)
@@ -201,7 +407,8 @@ func (e *EventListener) parseFilteredBlock(block *peer.DeliverResponse_FilteredB
 				transaction.ChainCodeId = data.TransactionActions.ChaincodeActions[0].ChaincodeEvent.ChaincodeId
 				for _, e := range data.TransactionActions.ChaincodeActions {
 					transaction.Events = append(transaction.Events, EventBlockResponseTransactionEvent{
-						Name: e.ChaincodeEvent.EventName,
+						Name:        e.ChaincodeEvent.EventName,
+						ChainCodeId: e.ChaincodeEvent.ChaincodeId,
 					})
 				}
 			}
@@ -216,82 +423,40 @@ func (e *EventListener) parseFilteredBlock(block *peer.DeliverResponse_FilteredB
 
 func (e *EventListener) parseFullBlock(block *peer.DeliverResponse_Block, fullBlock bool) (*EventBlockResponse) {
 
-	response := &EventBlockResponse{
-		BlockHeight: block.Block.Header.Number,
-	}
+	response := &EventBlockResponse{block: block.Block, BlockHeight: block.Block.GetHeader().GetNumber()}
 	if fullBlock {
 		m, err := proto.Marshal(block.Block)
 		if err != nil {
-			response.Error = err
+			response.Error = &BlockDecodeError{Number: response.BlockHeight, Err: err}
 			return response
 		}
 		response.RawBlock = m
 	}
-	for idx, pl := range block.Block.Data.Data {
-		transaction := EventBlockResponseTransaction{}
-		envelope := new(common.Envelope)
-		payload := new(common.Payload)
-		header := new(common.ChannelHeader)
-		ex := &peer.ChaincodeHeaderExtension{}
-		if err := proto.Unmarshal(pl, envelope); err != nil {
-			response.Error = err
-			return response
-		}
-		if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
-			response.Error = err
-		}
-		if err := proto.Unmarshal(payload.Header.ChannelHeader, header); err != nil {
-			response.Error = err
-			return response
-		}
-		if err := proto.Unmarshal(header.Extension, ex); err != nil {
-			response.Error = err
-			return response
+	decoded, err := DecodeBlock(block.Block)
+	if err != nil {
+		response.Error = &BlockDecodeError{Number: response.BlockHeight, Err: err}
+		return response
+	}
+	response.Transactions = make([]EventBlockResponseTransaction, 0, len(decoded.Envelopes))
+	for _, env := range decoded.Envelopes {
+		response.ChannelId = env.ChannelHeader.ChannelId
+		transaction := EventBlockResponseTransaction{
+			Id:     env.ChannelHeader.TxID,
+			Type:   common.HeaderType_name[int32(env.ChannelHeader.Type)],
+			Status: peer.TxValidationCode_name[int32(env.ValidationCode)],
 		}
-
-		response.ChannelId = header.ChannelId
-		transaction.Id = header.TxId
-
-		transaction.Status = peer.TxValidationCode_name[int32(block.Block.Metadata.Metadata[2][idx])]
-		transaction.Type = common.HeaderType_name[header.Type]
-		if common.HeaderType(header.Type) == common.HeaderType_ENDORSER_TRANSACTION {
-			transaction.ChainCodeId = ex.ChaincodeId.Name
-			tx := &peer.Transaction{}
-			err := proto.Unmarshal(payload.Data, tx)
-			if err != nil {
-				response.Error = err
-				return response
-			}
-
-			chainCodeActionPayload := &peer.ChaincodeActionPayload{}
-			err = proto.Unmarshal(tx.Actions[0].Payload, chainCodeActionPayload)
-			if err != nil {
-				response.Error = err
-				return response
-			}
-
-			propRespPayload := &peer.ProposalResponsePayload{}
-			err = proto.Unmarshal(chainCodeActionPayload.Action.ProposalResponsePayload, propRespPayload)
-			if err != nil {
-				response.Error = err
-				return response
-			}
-
-			caPayload := &peer.ChaincodeAction{}
-			err = proto.Unmarshal(propRespPayload.Extension, caPayload)
-			if err != nil {
-				response.Error = err
-				return response
-			}
-			ccEvent := &peer.ChaincodeEvent{}
-			err = proto.Unmarshal(caPayload.Events, ccEvent)
-			if err != nil {
-				response.Error = err
-				return response
-			}
-			if ccEvent != nil {
-				transaction.Events = append(transaction.Events,
-					EventBlockResponseTransactionEvent{Name: ccEvent.EventName, Value: ccEvent.Payload})
+		if env.Transaction != nil {
+			for _, action := range env.Transaction.Actions {
+				if transaction.ChainCodeId == "" {
+					transaction.ChainCodeId = action.ChainCodeName
+				}
+				if action.Event != nil {
+					transaction.Events = append(transaction.Events, EventBlockResponseTransactionEvent{
+						Name:        action.Event.EventName,
+						Value:       action.Event.Payload,
+						ChainCodeId: action.Event.ChaincodeId,
+					})
+				}
 			}
 		}
 		response.Transactions = append(response.Transactions, transaction)
@@ -312,15 +477,15 @@ func (e *EventListener) createSeekEnvelope(start *orderer.SeekPosition, stop *or
 	}
 
 	channelHeader, err := proto.Marshal(&common.ChannelHeader{
-		Type:    int32(common.HeaderType_DELIVER_SEEK_INFO),
-		Version: 0,
+		Type:        int32(common.HeaderType_DELIVER_SEEK_INFO),
+		Version:     0,
 		Timestamp: &timestamp.Timestamp{
 			Seconds: time.Now().Unix(),
 			Nanos:   0,
 		},
-		ChannelId: e.ChannelId,
-		Epoch:     0,
-		// TlsCertHash:[]
+		ChannelId:   e.ChannelId,
+		Epoch:       0,
+		TlsCertHash: e.Peer.TlsCertHash,
 	})
 	if err != nil {
 		return nil, err
@@ -369,6 +534,7 @@ func NewEventListener(ctx context.Context, crypto CryptoSuite, identity Identity
 
 	listener := EventListener{
 		Context:      ctx,
+		done:         make(chan struct{}),
 		Peer:         p,
 		Identity:     identity,
 		ChannelId:    channelId,
@@ -377,9 +543,15 @@ func NewEventListener(ctx context.Context, crypto CryptoSuite, identity Identity
 		FullBlock:    false,
 	}
 
+	listener.ctx, listener.cancel = context.WithCancel(ctx)
 	if err := listener.newConnection(); err != nil {
+		listener.cancel()
+		if listener.connection != nil {
+			listener.connection.Close()
+		}
 		return nil, err
 	}
+	go listener.shutdown()
 
 	return &listener, nil
 }
\ No newline at end of file
@@ -0,0 +1,328 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultEventServiceMaxLag        = 3
+	defaultEventServiceCheckInterval = 5 * time.Second
+)
+
+// errEventPeerLagging stops consuming from peer that is behind other peers
+var errEventPeerLagging = errors.New("event peer is behind other peers")
+
+// EventServiceOptions define how EventService selects event peers. Checkpoint and reconnect options are same as for
+// ReconnectingListener, default checkpoint key is channel id.
+type EventServiceOptions struct {
+	ReconnectOptions
+	// MaxLag is the number of blocks the current peer can be behind the best peer before service switches to the
+	// best peer. Default is 3.
+	MaxLag uint64
+	// CheckInterval is how often the current peer is compared with other peers. Default is 5 seconds.
+	CheckInterval time.Duration
+}
+
+// EventService delivers blocks of one channel from a set of event peers. Blocks are consumed from one peer at a time,
+// the one with the highest ledger height. Heights of all peers are watched with filtered block streams, and when the
+// current peer disconnects or is more than MaxLag blocks behind, service continues from the best peer at the next
+// block. Every block is delivered exactly once and in order, no matter how many peers are used.
+type EventService struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	crypto       CryptoSuite
+	identity     Identity
+	peers        []*Peer
+	channelId    string
+	listenerType int
+	options      EventServiceOptions
+	verifier     *BlockVerifier
+	mutex        sync.Mutex
+	heights      map[string]uint64
+	healthy      map[string]bool
+	current      string
+	next         uint64
+	positioned   bool
+}
+
+// NewEventService creates event service for channel. Order of peers is used as preference when peers have the same
+// height. Nothing is send to peers until `Listen` is called.
+func NewEventService(ctx context.Context, crypto CryptoSuite, identity Identity, peers []*Peer, channelId string,
+	listenerType int, options EventServiceOptions) (*EventService, error) {
+	if crypto == nil {
+		return nil, fmt.Errorf("cryptoSuite cannot be nil")
+	}
+	if len(peers) == 0 {
+		return nil, ErrPeerNameNotFound
+	}
+	if err := options.setDefaults(channelId, listenerType); err != nil {
+		return nil, err
+	}
+	next, positioned, err := options.startPosition()
+	if err != nil {
+		return nil, err
+	}
+	if options.MaxLag == 0 {
+		options.MaxLag = defaultEventServiceMaxLag
+	}
+	if options.CheckInterval <= 0 {
+		options.CheckInterval = defaultEventServiceCheckInterval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s := &EventService{
+		ctx:          ctx,
+		cancel:       cancel,
+		crypto:       crypto,
+		identity:     identity,
+		peers:        peers,
+		channelId:    channelId,
+		listenerType: listenerType,
+		options:      options,
+		heights:      make(map[string]uint64),
+		healthy:      make(map[string]bool),
+		next:         next,
+		positioned:   positioned,
+	}
+	if options.VerifyBlocks {
+		s.verifier = NewBlockVerifier()
+	}
+	return s, nil
+}
+
+// NewEventService creates event service for channel using event peers with provided names. If eventPeers is empty all
+// configured event peers are used.
+func (c *FabricClient) NewEventService(ctx context.Context, identity Identity, channelId string, eventPeers []string,
+	listenerType int, options EventServiceOptions) (*EventService, error) {
+	if len(eventPeers) == 0 {
+		for name := range c.EventPeers {
+			eventPeers = append(eventPeers, name)
+		}
+		sort.Strings(eventPeers)
+	}
+	peers := c.getEventPeers(eventPeers)
+	if len(peers) != len(eventPeers) {
+		return nil, ErrPeerNameNotFound
+	}
+	return NewEventService(ctx, c.Crypto, identity, peers, channelId, listenerType, options)
+}
+
+// Listen starts watching peers and delivering blocks in new goroutine. Only fatal errors are send to response and
+// response is closed when service stops, same as for ReconnectingListener. With single peer its height is not watched, so only one stream is used.
+func (s *EventService) Listen(response chan<- EventBlockResponse) {
+	if len(s.peers) > 1 {
+		for _, p := range s.peers {
+			go s.watch(p)
+		}
+	}
+	go s.run(response)
+}
+
+// Stop stops the service and all its event streams, response is closed after that
+func (s *EventService) Stop() {
+	s.cancel()
+}
+
+// Checkpoint saves block as the last processed block. It is needed only with ManualCheckpoint option.
+func (s *EventService) Checkpoint(blockNumber uint64) error {
+	if err := s.options.Store.Save(s.options.Key, blockNumber); err != nil {
+		return &CheckpointError{Key: s.options.Key, Err: err}
+	}
+	return nil
+}
+
+// Peer returns name of the peer that blocks are currently consumed from
+func (s *EventService) Peer() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.current
+}
+
+func (s *EventService) run(response chan<- EventBlockResponse) {
+	// watchers are stopped together with the service
+	defer s.cancel()
+	backoff := s.options.newBackoff()
+	var failed string
+	for {
+		p := s.selectPeer(failed)
+		progress, err := s.consume(p, response)
+		if s.ctx.Err() != nil {
+			finishListener(s.ctx, response, s.ctx.Err())
+			return
+		}
+		if fatalListenerError(err) {
+			finishListener(s.ctx, response, err)
+			return
+		}
+		if err == errEventPeerLagging {
+			failed = ""
+			continue
+		}
+		failed = p.Name
+		if progress {
+			backoff.reset()
+		}
+		select {
+		case <-time.After(jitter(backoff.next())):
+		case <-s.ctx.Done():
+			finishListener(s.ctx, response, s.ctx.Err())
+			return
+		}
+	}
+}
+
+// selectPeer returns healthy peer with the highest height. Peer that just failed is used only if there is no other
+// healthy peer. When no peer is known to be healthy peers are tried in order.
+func (s *EventService) selectPeer(failed string) *Peer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var best *Peer
+	for _, p := range s.peers {
+		if !s.healthy[p.Name] || p.Name == failed {
+			continue
+		}
+		if best == nil || s.heights[p.Name] > s.heights[best.Name] ||
+			(s.heights[p.Name] == s.heights[best.Name] && p.Name == s.current) {
+			best = p
+		}
+	}
+	if best == nil {
+		best = s.peers[0]
+		for i, p := range s.peers {
+			if p.Name == failed {
+				best = s.peers[(i+1)%len(s.peers)]
+				break
+			}
+		}
+	}
+	s.current = best.Name
+	return best
+}
+
+// lagging reports whether peer is more than MaxLag blocks behind the best healthy peer
+func (s *EventService) lagging(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	height := s.heights[name]
+	for _, p := range s.peers {
+		if s.healthy[p.Name] && s.heights[p.Name] > height+s.options.MaxLag {
+			return true
+		}
+	}
+	return false
+}
+
+// consume forwards blocks from peer until stream fails, peer is lagging or ctx is done. Blocks that were already
+// delivered from other peer are skipped.
+func (s *EventService) consume(p *Peer, response chan<- EventBlockResponse) (progress bool, err error) {
+	listener, err := NewEventListener(s.ctx, s.crypto, s.identity, *p, s.channelId, s.listenerType)
+	if err != nil {
+		return false, err
+	}
+	defer listener.Close()
+	listener.FullBlock = s.options.FullBlock
+
+	s.mutex.Lock()
+	next, positioned := s.next, s.positioned
+	s.mutex.Unlock()
+	if positioned {
+		err = listener.SeekRange(next, math.MaxUint64)
+	} else {
+		err = listener.SeekNewest()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ch := make(chan EventBlockResponse)
+	listener.Listen(ch)
+	ticker := time.NewTicker(s.options.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case block, ok := <-ch:
+			if !ok {
+				return progress, listener.Err()
+			}
+			if block.Error != nil {
+				return progress, block.Error
+			}
+			if positioned && block.BlockHeight < next {
+				continue
+			}
+			if positioned && block.BlockHeight > next {
+				return progress, fmt.Errorf("peer %s skipped blocks %d to %d", p.Name, next, block.BlockHeight-1)
+			}
+			// block is verified only when it is delivered, listener may have received blocks that are never
+			// delivered because service switched to other peer
+			if s.verifier != nil {
+				if err := s.verifier.Verify(block.block); err != nil {
+					return progress, err
+				}
+			}
+			select {
+			case response <- block:
+			case <-s.ctx.Done():
+				return progress, s.ctx.Err()
+			}
+			progress = true
+			next, positioned = block.BlockHeight+1, true
+			s.mutex.Lock()
+			s.next, s.positioned = next, positioned
+			s.mutex.Unlock()
+			if !s.options.ManualCheckpoint {
+				if err := s.Checkpoint(block.BlockHeight); err != nil {
+					return progress, err
+				}
+			}
+		case <-ticker.C:
+			if s.lagging(p.Name) {
+				return progress, errEventPeerLagging
+			}
+		case <-s.ctx.Done():
+			return progress, s.ctx.Err()
+		}
+	}
+}
+
+// watch follows ledger height of peer with filtered block stream, and reconnects when stream fails.
+func (s *EventService) watch(p *Peer) {
+	backoff := s.options.newBackoff()
+	for {
+		listener, err := NewEventListener(s.ctx, s.crypto, s.identity, *p, s.channelId, EventTypeFiltered)
+		if err == nil {
+			if err = listener.SeekNewest(); err == nil {
+				ch := make(chan EventBlockResponse)
+				listener.Listen(ch)
+				for block := range ch {
+					if block.Error != nil {
+						break
+					}
+					s.mutex.Lock()
+					s.heights[p.Name] = block.BlockHeight + 1
+					s.healthy[p.Name] = true
+					s.mutex.Unlock()
+					backoff.reset()
+				}
+			}
+			listener.Close()
+		}
+		s.mutex.Lock()
+		s.healthy[p.Name] = false
+		s.mutex.Unlock()
+		select {
+		case <-time.After(jitter(backoff.next())):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+	"github.com/hyperledger/fabric/protos/peer"
+	"google.golang.org/grpc"
+)
+
+// testChain creates chain of n empty blocks with valid data hashes and previous hashes
+func testChain(t *testing.T, n int) []*common.Block {
+	var blocks []*common.Block
+	var previous []byte
+	for i := 0; i < n; i++ {
+		data := &common.BlockData{}
+		header := &common.BlockHeader{Number: uint64(i), PreviousHash: previous, DataHash: BlockDataHash(data)}
+		hash, err := BlockHeaderHash(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		previous = hash
+		blocks = append(blocks, &common.Block{Header: header, Data: data, Metadata: &common.BlockMetadata{}})
+	}
+	return blocks
+}
+
+// testDeliverServer sends blocks from seek start position and then waits until stream is closed
+type testDeliverServer struct {
+	blocks []*common.Block
+}
+
+func (s *testDeliverServer) Deliver(stream peer.Deliver_DeliverServer) error {
+	return s.deliver(stream, stream.Context(), func(b *common.Block) *peer.DeliverResponse {
+		return &peer.DeliverResponse{Type: &peer.DeliverResponse_Block{Block: b}}
+	})
+}
+
+func (s *testDeliverServer) DeliverFiltered(stream peer.Deliver_DeliverFilteredServer) error {
+	return s.deliver(stream, stream.Context(), func(b *common.Block) *peer.DeliverResponse {
+		return &peer.DeliverResponse{Type: &peer.DeliverResponse_FilteredBlock{
+			FilteredBlock: &peer.FilteredBlock{ChannelId: "testchannel", Number: b.Header.Number}}}
+	})
+}
+
+func (s *testDeliverServer) deliver(stream interface {
+	Send(*peer.DeliverResponse) error
+	Recv() (*common.Envelope, error)
+}, ctx context.Context, convert func(*common.Block) *peer.DeliverResponse) error {
+	envelope, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	payload := new(common.Payload)
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return err
+	}
+	seek := new(orderer.SeekInfo)
+	if err := proto.Unmarshal(payload.Data, seek); err != nil {
+		return err
+	}
+	start := uint64(len(s.blocks) - 1)
+	if specified := seek.Start.GetSpecified(); specified != nil {
+		start = specified.Number
+	} else if seek.Start.GetOldest() != nil {
+		start = 0
+	}
+	if start > uint64(len(s.blocks)) {
+		start = uint64(len(s.blocks))
+	}
+	for _, b := range s.blocks[start:] {
+		if err := stream.Send(convert(b)); err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func startTestPeer(t *testing.T, name string, blocks []*common.Block) *Peer {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	peer.RegisterDeliverServer(server, &testDeliverServer{blocks: blocks})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return &Peer{Name: name, Uri: lis.Addr().String(), Opts: []grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock()}}
+}
+
+func testIdentity(t *testing.T) (CryptoSuite, Identity) {
+	crypto, err := NewECCryptSuiteFromConfig(CryptoConfig{Family: "ecdsa", Algorithm: "P256-SHA256", Hash: "SHA2-256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypto, Identity{Certificate: cert, PrivateKey: key, MspId: "Org1MSP"}
+}
+
+// Consumer is slow, so when service switches from lagging peer its listener already holds next block that is never
+// delivered. Verification must continue from the last delivered block.
+func TestEventServiceVerifiesBlocksAcrossPeerSwitch(t *testing.T) {
+	chain := testChain(t, 20)
+	lagging := startTestPeer(t, "lagging", chain[:10])
+	best := startTestPeer(t, "best", chain)
+	crypto, identity := testIdentity(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	service, err := NewEventService(ctx, crypto, identity, []*Peer{lagging, best}, "testchannel", EventTypeFullBlock,
+		EventServiceOptions{
+			ReconnectOptions: ReconnectOptions{StartFromOldest: true, VerifyBlocks: true},
+			CheckInterval:    10 * time.Millisecond,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := make(chan EventBlockResponse)
+	service.Listen(response)
+	defer service.Stop()
+
+	for i := uint64(0); i < uint64(len(chain)); i++ {
+		if i < 5 {
+			// let the service notice lagging peer while block is waiting for consumer
+			time.Sleep(30 * time.Millisecond)
+		}
+		block, ok := <-response
+		if !ok {
+			t.Fatalf("response closed before block %d", i)
+		}
+		if block.Error != nil {
+			t.Fatalf("block %d: %v", i, block.Error)
+		}
+		if block.BlockHeight != i {
+			t.Fatalf("expected block %d, got %d", i, block.BlockHeight)
+		}
+	}
+	if p := service.Peer(); p != "best" {
+		t.Fatalf("expected service to switch to best peer, it uses %s", p)
+	}
+}
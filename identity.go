@@ -5,6 +5,7 @@ License: Apache License Version 2.0
 package gohfc
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/base64"
@@ -14,18 +15,34 @@ import (
 )
 
 // Identity is participant public and private key
+// PrivateKey is *ecdsa.PrivateKey or any crypto.Signer with ECDSA key, for example client of remote signing service.
 type Identity struct {
 	Certificate *x509.Certificate
 	PrivateKey  interface{}
 	MspId       string
 }
 
+// NewIdentityFromSigner creates identity which private key is available only through signer.
+// Signer public key must match certificate public key.
+func NewIdentityFromSigner(cert *x509.Certificate, signer crypto.Signer, mspId string) (*Identity, error) {
+	pub, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidKeyType
+	}
+	certPub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || certPub.X.Cmp(pub.X) != 0 || certPub.Y.Cmp(pub.Y) != 0 {
+		return nil, ErrSignerCertificateMismatch
+	}
+	return &Identity{Certificate: cert, PrivateKey: signer, MspId: mspId}, nil
+}
+
 // EnrollmentId get enrollment id from certificate
 func (i *Identity) EnrollmentId() string {
 	return i.Certificate.Subject.CommonName
 }
 
-// EnrollmentId get enrollment id from certificate
+// ToPem returns certificate and private key in PEM format.
+// If private key is crypto.Signer it cannot be exported, so only certificate is returned and private key is nil.
 func (i *Identity) ToPem() ([]byte, []byte, error) {
 
 	switch i.PrivateKey.(type) {
@@ -38,6 +55,9 @@ func (i *Identity) ToPem() ([]byte, []byte, error) {
 		privateKey := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b})
 		cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.Certificate.Raw})
 		return cert, privateKey, nil
+	case crypto.Signer:
+		cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.Certificate.Raw})
+		return cert, nil, nil
 
 	default:
 		return nil, nil, ErrInvalidKeyType
@@ -45,6 +65,8 @@ func (i *Identity) ToPem() ([]byte, []byte, error) {
 }
 
 // MarshalIdentity marshal identity to string
+// If private key is crypto.Signer only certificate and MSP id are marshaled. After `UnmarshalIdentity` signer must be
+// set again as PrivateKey.
 func MarshalIdentity(i *Identity) (string, error) {
 
 	var pk, cert string
@@ -57,13 +79,19 @@ func MarshalIdentity(i *Identity) (string, error) {
 		}
 		block := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b})
 		pk = base64.RawStdEncoding.EncodeToString(block)
+	case crypto.Signer:
+		// private key is not exportable
 
 	default:
 		return "", ErrInvalidKeyType
 	}
 
 	cert = base64.RawStdEncoding.EncodeToString(i.Certificate.Raw)
-	str, err := json.Marshal(map[string]string{"cert": cert, "pk": pk, "mspid": i.MspId})
+	data := map[string]string{"cert": cert, "mspid": i.MspId}
+	if pk != "" {
+		data["pk"] = pk
+	}
+	str, err := json.Marshal(data)
 	if err != nil {
 		return "", err
 	}
@@ -72,6 +100,7 @@ func MarshalIdentity(i *Identity) (string, error) {
 }
 
 // UnmarshalIdentity unmarshal identity from string
+// Identity marshaled with crypto.Signer has no private key, so PrivateKey is nil.
 func UnmarshalIdentity(data string) (*Identity, error) {
 	var raw map[string]string
 	if err := json.Unmarshal([]byte(data), &raw); err != nil {
@@ -81,10 +110,6 @@ func UnmarshalIdentity(data string) (*Identity, error) {
 	if _, ok := raw["cert"]; !ok || len(raw["cert"]) < 1 {
 		return nil, ErrInvalidDataForParcelIdentity
 	}
-	if _, ok := raw["pk"]; !ok || len(raw["pk"]) < 1 {
-		return nil, ErrInvalidDataForParcelIdentity
-	}
-
 	certRaw, err := base64.RawStdEncoding.DecodeString(raw["cert"])
 	if err != nil {
 		return nil, err
@@ -93,6 +118,9 @@ func UnmarshalIdentity(data string) (*Identity, error) {
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := raw["pk"]; !ok || len(raw["pk"]) < 1 {
+		return &Identity{Certificate: cert, MspId: raw["mspid"]}, nil
+	}
 
 	keyRaw, err := base64.RawStdEncoding.DecodeString(raw["pk"])
 	if err != nil {
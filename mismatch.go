@@ -0,0 +1,317 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/peer"
+	"sort"
+	"strings"
+)
+
+// DifferenceKind is the part of endorsement where peers disagree
+type DifferenceKind string
+
+const (
+	// DifferenceRead is different version of the key read by chaincode
+	DifferenceRead DifferenceKind = "read"
+	// DifferenceWrite is different value written to the key or different delete flag
+	DifferenceWrite DifferenceKind = "write"
+	// DifferenceRangeQuery is different result of range query
+	DifferenceRangeQuery DifferenceKind = "range query"
+	// DifferenceCollection is different hash of private data collection read/write set
+	DifferenceCollection DifferenceKind = "collection"
+	// DifferenceResponse is different status, message or payload returned by chaincode
+	DifferenceResponse DifferenceKind = "response"
+	// DifferenceEvent is different chaincode event
+	DifferenceEvent DifferenceKind = "event"
+	// DifferenceChaincode is different chaincode name or version
+	DifferenceChaincode DifferenceKind = "chaincode"
+	// DifferencePayload is used when response payload cannot be decoded, so only raw bytes are compared
+	DifferencePayload DifferenceKind = "payload"
+)
+
+// missingValue is used in differences when key is not present in one of the responses
+const missingValue = "<missing>"
+
+// EndorsementMismatchError is returned when peers endorse the proposal with different results. Usually this means
+// that chaincode is not deterministic or that some peers are behind with the ledger.
+// Peers with identical responses are grouped, and every group is compared with the first one.
+type EndorsementMismatchError struct {
+	// Groups are names of peers with identical responses. First group is used as reference.
+	Groups [][]string
+	// Differences between reference group and other groups
+	Differences []*EndorsementDifference
+}
+
+// EndorsementDifference is single difference between reference peers and other peers.
+type EndorsementDifference struct {
+	Kind DifferenceKind
+	// Namespace is chaincode namespace of the key. Empty for response, event, chaincode and payload differences.
+	Namespace string
+	// Key is the ledger key, range query "start..end", collection name or response field
+	Key string
+	// Reference are peers from reference group, Peers are peers that differ from them
+	Reference []string
+	Peers     []string
+	// Expected is the value from reference peers and Actual is value from other peers.
+	// Versions are formatted as "block:tx" and missing entries as "<missing>".
+	Expected string
+	Actual   string
+}
+
+func (e *EndorsementMismatchError) Error() string {
+	if len(e.Differences) == 0 {
+		return fmt.Sprintf("%s: peer groups %v", ErrEndorsementsDoNotMatch, e.Groups)
+	}
+	return fmt.Sprintf("%s: peer groups %v, %d differences, first is %s", ErrEndorsementsDoNotMatch, e.Groups,
+		len(e.Differences), e.Differences[0])
+}
+
+// Unwrap allows errors.Is(err, ErrEndorsementsDoNotMatch)
+func (e *EndorsementMismatchError) Unwrap() error {
+	return ErrEndorsementsDoNotMatch
+}
+
+func (d *EndorsementDifference) String() string {
+	key := d.Key
+	if d.Namespace != "" {
+		key = d.Namespace + "/" + d.Key
+	}
+	return fmt.Sprintf("%s %s: %v has %q, %v has %q", d.Kind, key, d.Reference, shorten(d.Expected), d.Peers,
+		shorten(d.Actual))
+}
+
+func shorten(s string) string {
+	if len(s) > 64 {
+		return s[:64] + "..."
+	}
+	return s
+}
+
+// endorsedAction is decoded proposal response payload in form that is easy to compare
+type endorsedAction struct {
+	chaincode  string
+	response   map[string]string
+	event      map[string]string
+	namespaces map[string]*namespaceAction
+}
+
+type namespaceAction struct {
+	reads       map[string]string
+	writes      map[string]string
+	ranges      map[string]string
+	collections map[string]string
+}
+
+// newEndorsementMismatchError groups successful responses by payload and compares decoded groups.
+func newEndorsementMismatchError(responses []*PeerResponse) *EndorsementMismatchError {
+	var payloads [][]byte
+	result := new(EndorsementMismatchError)
+	for _, r := range responses {
+		if r.Err != nil || r.Response.GetResponse().GetStatus() != 200 {
+			continue
+		}
+		found := false
+		for i, pl := range payloads {
+			if bytes.Equal(pl, r.Response.Payload) {
+				result.Groups[i] = append(result.Groups[i], r.Name)
+				found = true
+				break
+			}
+		}
+		if !found {
+			payloads = append(payloads, r.Response.Payload)
+			result.Groups = append(result.Groups, []string{r.Name})
+		}
+	}
+	if len(payloads) < 2 {
+		return result
+	}
+	reference, refErr := decodeEndorsedAction(payloads[0])
+	for i := 1; i < len(payloads); i++ {
+		other, err := decodeEndorsedAction(payloads[i])
+		d := &differ{reference: result.Groups[0], peers: result.Groups[i]}
+		if refErr != nil || err != nil {
+			d.add(DifferencePayload, "", "", fmt.Sprintf("%x", sha256.Sum256(payloads[0])),
+				fmt.Sprintf("%x", sha256.Sum256(payloads[i])))
+		} else {
+			d.compare(reference, other)
+		}
+		result.Differences = append(result.Differences, d.differences...)
+	}
+	return result
+}
+
+func decodeEndorsedAction(payload []byte) (*endorsedAction, error) {
+	responsePayload := new(peer.ProposalResponsePayload)
+	if err := proto.Unmarshal(payload, responsePayload); err != nil {
+		return nil, err
+	}
+	action := new(peer.ChaincodeAction)
+	if err := proto.Unmarshal(responsePayload.Extension, action); err != nil {
+		return nil, err
+	}
+	result := &endorsedAction{
+		chaincode:  fmt.Sprintf("%s:%s", action.GetChaincodeId().GetName(), action.GetChaincodeId().GetVersion()),
+		response:   make(map[string]string),
+		event:      make(map[string]string),
+		namespaces: make(map[string]*namespaceAction),
+	}
+	if action.Response != nil {
+		result.response["status"] = fmt.Sprint(action.Response.Status)
+		result.response["message"] = action.Response.Message
+		result.response["payload"] = string(action.Response.Payload)
+	}
+	if len(action.Events) > 0 {
+		event := new(peer.ChaincodeEvent)
+		if err := proto.Unmarshal(action.Events, event); err != nil {
+			return nil, err
+		}
+		result.event["name"] = event.EventName
+		result.event["payload"] = string(event.Payload)
+	}
+	if len(action.Results) == 0 {
+		return result, nil
+	}
+	rwSet, err := DecodeReadWriteSet(action.Results)
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range rwSet.Namespaces {
+		na := &namespaceAction{
+			reads:       make(map[string]string),
+			writes:      make(map[string]string),
+			ranges:      make(map[string]string),
+			collections: make(map[string]string),
+		}
+		for _, r := range ns.Reads {
+			na.reads[r.Key] = formatVersion(r.Version)
+		}
+		for _, w := range ns.Writes {
+			if w.IsDelete {
+				na.writes[w.Key] = "<deleted>"
+			} else {
+				na.writes[w.Key] = string(w.Value)
+			}
+		}
+		for _, rq := range ns.RangeQueries {
+			na.ranges[rq.StartKey+".."+rq.EndKey] = formatRangeQuery(rq)
+		}
+		for _, c := range ns.Collections {
+			na.collections[c.CollectionName] = fmt.Sprintf("%x", c.PvtRwSetHash)
+		}
+		result.namespaces[ns.Namespace] = na
+	}
+	return result, nil
+}
+
+func formatVersion(v *Version) string {
+	if v == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%d:%d", v.BlockNum, v.TxNum)
+}
+
+func formatRangeQuery(rq *RangeQuery) string {
+	parts := []string{fmt.Sprintf("exhausted=%t", rq.Exhausted)}
+	for _, r := range rq.Reads {
+		parts = append(parts, r.Key+"@"+formatVersion(r.Version))
+	}
+	if rq.MerkleSummary != nil {
+		for _, h := range rq.MerkleSummary.MaxLevelHashes {
+			parts = append(parts, fmt.Sprintf("%x", h))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// differ collects differences between two decoded actions
+type differ struct {
+	reference   []string
+	peers       []string
+	differences []*EndorsementDifference
+}
+
+func (d *differ) add(kind DifferenceKind, namespace, key, expected, actual string) {
+	d.differences = append(d.differences, &EndorsementDifference{Kind: kind, Namespace: namespace, Key: key,
+		Reference: d.reference, Peers: d.peers, Expected: expected, Actual: actual})
+}
+
+func (d *differ) compare(reference, other *endorsedAction) {
+	if reference.chaincode != other.chaincode {
+		d.add(DifferenceChaincode, "", "", reference.chaincode, other.chaincode)
+	}
+	d.compareMaps(DifferenceResponse, "", reference.response, other.response)
+	d.compareMaps(DifferenceEvent, "", reference.event, other.event)
+
+	empty := &namespaceAction{}
+	for _, ns := range unionKeys(namespaceKeys(reference.namespaces), namespaceKeys(other.namespaces)) {
+		ref, ok := reference.namespaces[ns]
+		if !ok {
+			ref = empty
+		}
+		oth, ok := other.namespaces[ns]
+		if !ok {
+			oth = empty
+		}
+		d.compareMaps(DifferenceRead, ns, ref.reads, oth.reads)
+		d.compareMaps(DifferenceWrite, ns, ref.writes, oth.writes)
+		d.compareMaps(DifferenceRangeQuery, ns, ref.ranges, oth.ranges)
+		d.compareMaps(DifferenceCollection, ns, ref.collections, oth.collections)
+	}
+}
+
+func (d *differ) compareMaps(kind DifferenceKind, namespace string, reference, other map[string]string) {
+	for _, key := range unionKeys(mapKeys(reference), mapKeys(other)) {
+		expected, ok := reference[key]
+		if !ok {
+			expected = missingValue
+		}
+		actual, ok := other[key]
+		if !ok {
+			actual = missingValue
+		}
+		if expected != actual {
+			d.add(kind, namespace, key, expected, actual)
+		}
+	}
+}
+
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func namespaceKeys(m map[string]*namespaceAction) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// unionKeys returns sorted unique keys from both lists
+func unionKeys(a, b []string) []string {
+	set := make(map[string]bool, len(a)+len(b))
+	for _, k := range a {
+		set[k] = true
+	}
+	for _, k := range b {
+		set[k] = true
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,85 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/ledger/rwset"
+	"github.com/hyperledger/fabric/protos/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// testEndorsement creates successful peer response with key "a" read at readBlock, value written to key "b" and
+// chaincode response payload
+func testEndorsement(t *testing.T, name string, readBlock uint64, value, payload string) *PeerResponse {
+	marshal := func(m proto.Message) []byte {
+		b, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	kvSet := &kvrwset.KVRWSet{
+		Reads:  []*kvrwset.KVRead{{Key: "a", Version: &kvrwset.Version{BlockNum: readBlock}}},
+		Writes: []*kvrwset.KVWrite{{Key: "b", Value: []byte(value)}},
+	}
+	txSet := &rwset.TxReadWriteSet{DataModel: rwset.TxReadWriteSet_KV,
+		NsRwset: []*rwset.NsReadWriteSet{{Namespace: "mycc", Rwset: marshal(kvSet)}}}
+	action := &peer.ChaincodeAction{
+		ChaincodeId: &peer.ChaincodeID{Name: "mycc", Version: "1.0"},
+		Results:     marshal(txSet),
+		Response:    &peer.Response{Status: 200, Payload: []byte(payload)},
+	}
+	responsePayload := marshal(&peer.ProposalResponsePayload{Extension: marshal(action)})
+	return &PeerResponse{Name: name, Response: &peer.ProposalResponse{
+		Response: &peer.Response{Status: 200},
+		Payload:  responsePayload,
+	}}
+}
+
+func TestEndorsementMismatchError(t *testing.T) {
+	responses := []*PeerResponse{
+		testEndorsement(t, "peer0", 1, "1", "ok"),
+		testEndorsement(t, "peer1", 2, "2", "changed"),
+		testEndorsement(t, "peer2", 1, "1", "ok"),
+		{Name: "peer3", Err: errors.New("unavailable")},
+	}
+	err := newEndorsementMismatchError(responses)
+
+	expectedGroups := [][]string{{"peer0", "peer2"}, {"peer1"}}
+	if !reflect.DeepEqual(err.Groups, expectedGroups) {
+		t.Fatalf("expected groups %v, got %v", expectedGroups, err.Groups)
+	}
+	reference, peers := []string{"peer0", "peer2"}, []string{"peer1"}
+	expected := []*EndorsementDifference{
+		{Kind: DifferenceResponse, Key: "payload", Reference: reference, Peers: peers, Expected: "ok", Actual: "changed"},
+		{Kind: DifferenceRead, Namespace: "mycc", Key: "a", Reference: reference, Peers: peers, Expected: "1:0", Actual: "2:0"},
+		{Kind: DifferenceWrite, Namespace: "mycc", Key: "b", Reference: reference, Peers: peers, Expected: "1", Actual: "2"},
+	}
+	if len(err.Differences) != len(expected) {
+		t.Fatalf("expected %d differences, got %d: %v", len(expected), len(err.Differences), err.Differences)
+	}
+	for i, d := range err.Differences {
+		if !reflect.DeepEqual(d, expected[i]) {
+			t.Errorf("difference %d: expected %s, got %s", i, expected[i], d)
+		}
+	}
+	if !errors.Is(err, ErrEndorsementsDoNotMatch) {
+		t.Fatal("expected error to match ErrEndorsementsDoNotMatch")
+	}
+}
+
+func TestEndorsementMismatchErrorUndecodablePayload(t *testing.T) {
+	broken := testEndorsement(t, "peer1", 1, "1", "ok")
+	broken.Response.Payload = []byte{0xff}
+	err := newEndorsementMismatchError([]*PeerResponse{testEndorsement(t, "peer0", 1, "1", "ok"), broken})
+	if len(err.Differences) != 1 || err.Differences[0].Kind != DifferencePayload {
+		t.Fatalf("expected single payload difference, got %v", err.Differences)
+	}
+}
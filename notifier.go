@@ -0,0 +1,296 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"github.com/hyperledger/fabric/protos/peer"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRegistrationTimeout is used when CommitNotifierOptions.Timeout is not set
+	defaultRegistrationTimeout = 10 * time.Minute
+	// defaultRecentBlocks is used when CommitNotifierOptions.RecentBlocks is not set
+	defaultRecentBlocks = 100
+)
+
+// CommitNotifierOptions define event peers and limits of CommitNotifier.
+type CommitNotifierOptions struct {
+	// EventPeers are names of event peers used to receive blocks. If empty all configured event peers are used.
+	EventPeers []string
+	// Timeout is how long registration waits for commit before it fails with ErrCommitTimeout. Default is 10 minutes.
+	Timeout time.Duration
+	// RecentBlocks is the number of last blocks which transactions are remembered, so registration of transaction that
+	// is already committed resolves immediately. Default is 100.
+	RecentBlocks int
+}
+
+// TxCommit is commit of single transaction observed in the channel.
+// Transaction is committed in block BlockNumber, but it changes the ledger only when ValidationCode is VALID.
+type TxCommit struct {
+	TxID           string
+	BlockNumber    uint64
+	ValidationCode peer.TxValidationCode
+	// Events are chaincode events emitted by the transaction. Only event names are available.
+	Events []EventBlockResponseTransactionEvent
+	// EventPeer is the name of event peer that reported the commit
+	EventPeer string
+}
+
+// CommitFuture is registration of transaction in CommitNotifier. Result is available after Done channel is closed.
+type CommitFuture struct {
+	TxID     string
+	done     chan struct{}
+	once     sync.Once
+	commit   *TxCommit
+	err      error
+	timer    *time.Timer
+	notifier *CommitNotifier
+}
+
+// CommitNotifier follows filtered blocks of one channel and resolves registered transactions when they are committed.
+// It is shared by all callers in the channel, so there is only one event stream no matter how many transactions are
+// in flight. Event peers are used as in EventService, so blocks are received from the best peer and stream is
+// reconnected when it fails.
+type CommitNotifier struct {
+	cancel  context.CancelFunc
+	service *EventService
+	options CommitNotifierOptions
+	mutex   sync.Mutex
+	waiting map[string][]*CommitFuture
+	// recent are commits from last RecentBlocks blocks, blocks holds their transaction ids from oldest to newest
+	recent map[string]*TxCommit
+	blocks [][]string
+	err    error
+	// ready is closed when first block is received or notifier fails. Stream starts from the newest block, so every
+	// block after it is received.
+	ready     chan struct{}
+	readyOnce sync.Once
+}
+
+// NewCommitNotifier starts following blocks of channel on peers. Identity is used to sign deliver requests.
+func NewCommitNotifier(crypto CryptoSuite, identity Identity, peers []*Peer, channelId string, options CommitNotifierOptions) (*CommitNotifier, error) {
+	if options.Timeout <= 0 {
+		options.Timeout = defaultRegistrationTimeout
+	}
+	if options.RecentBlocks <= 0 {
+		options.RecentBlocks = defaultRecentBlocks
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	service, err := NewEventService(ctx, crypto, identity, peers, channelId, EventTypeFiltered, EventServiceOptions{})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	n := &CommitNotifier{
+		cancel:  cancel,
+		service: service,
+		options: options,
+		waiting: make(map[string][]*CommitFuture),
+		recent:  make(map[string]*TxCommit),
+		ready:   make(chan struct{}),
+	}
+	ch := make(chan EventBlockResponse)
+	service.Listen(ch)
+	go n.receive(ch)
+	return n, nil
+}
+
+// CommitNotifier returns shared notifier for the channel and event peers, and starts new one if it does not exist or
+// if it is closed. Options other than EventPeers are used only when notifier is started.
+func (c *FabricClient) CommitNotifier(identity Identity, channelId string, options CommitNotifierOptions) (*CommitNotifier, error) {
+	eventPeers := options.EventPeers
+	if len(eventPeers) == 0 {
+		for name := range c.EventPeers {
+			eventPeers = append(eventPeers, name)
+		}
+		sort.Strings(eventPeers)
+	}
+	peers := c.getEventPeers(eventPeers)
+	if len(peers) == 0 || len(peers) != len(eventPeers) {
+		return nil, ErrPeerNameNotFound
+	}
+	key := channelId + "/" + strings.Join(eventPeers, ",")
+
+	c.notifiersMutex.Lock()
+	defer c.notifiersMutex.Unlock()
+	if n, ok := c.notifiers[key]; ok && n.Err() == nil {
+		return n, nil
+	}
+	n, err := NewCommitNotifier(c.Crypto, identity, peers, channelId, options)
+	if err != nil {
+		return nil, err
+	}
+	if c.notifiers == nil {
+		c.notifiers = make(map[string]*CommitNotifier)
+	}
+	c.notifiers[key] = n
+	return n, nil
+}
+
+// Register waits for commit of transaction. To be sure that commit is not missed, register transaction before it is
+// send to orderer, or at most RecentBlocks blocks after it is committed.
+// Until first block is received notifier does not know where the stream starts, so Register blocks until then, at most
+// for timeout. If timeout is 0 notifier Timeout is used.
+func (n *CommitNotifier) Register(txId string, timeout time.Duration) *CommitFuture {
+	if timeout <= 0 {
+		timeout = n.options.Timeout
+	}
+	f := &CommitFuture{TxID: txId, done: make(chan struct{}), notifier: n}
+	deadline := time.Now().Add(timeout)
+	wait := time.NewTimer(timeout)
+	select {
+	case <-n.ready:
+		wait.Stop()
+	case <-wait.C:
+		f.resolve(nil, ErrCommitTimeout)
+		return f
+	}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.err != nil {
+		f.resolve(nil, n.err)
+		return f
+	}
+	if commit, ok := n.recent[txId]; ok {
+		f.resolve(commit, nil)
+		return f
+	}
+	n.waiting[txId] = append(n.waiting[txId], f)
+	f.timer = time.AfterFunc(time.Until(deadline), func() {
+		n.remove(f)
+		f.resolve(nil, ErrCommitTimeout)
+	})
+	return f
+}
+
+// Err returns error when notifier is closed and cannot be used anymore
+func (n *CommitNotifier) Err() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.err
+}
+
+// Close stops the event stream. Pending registrations fail with context.Canceled.
+func (n *CommitNotifier) Close() {
+	n.cancel()
+}
+
+func (n *CommitNotifier) receive(ch <-chan EventBlockResponse) {
+	for block := range ch {
+		if block.Error != nil {
+			n.fail(block.Error)
+			return
+		}
+		eventPeer := n.service.Peer()
+		var resolved []*CommitFuture
+		var commits []*TxCommit
+		n.mutex.Lock()
+		ids := make([]string, 0, len(block.Transactions))
+		for _, tx := range block.Transactions {
+			commit := &TxCommit{
+				TxID:           tx.Id,
+				BlockNumber:    block.BlockHeight,
+				ValidationCode: peer.TxValidationCode(peer.TxValidationCode_value[tx.Status]),
+				Events:         tx.Events,
+				EventPeer:      eventPeer,
+			}
+			// first commit of transaction id is the one that counts, duplicates are always invalid
+			if _, ok := n.recent[tx.Id]; !ok {
+				n.recent[tx.Id] = commit
+				ids = append(ids, tx.Id)
+			}
+			for _, f := range n.waiting[tx.Id] {
+				resolved = append(resolved, f)
+				commits = append(commits, commit)
+			}
+			delete(n.waiting, tx.Id)
+		}
+		n.blocks = append(n.blocks, ids)
+		for len(n.blocks) > n.options.RecentBlocks {
+			for _, id := range n.blocks[0] {
+				delete(n.recent, id)
+			}
+			n.blocks = n.blocks[1:]
+		}
+		n.mutex.Unlock()
+		n.setReady()
+		for i, f := range resolved {
+			f.resolve(commits[i], nil)
+		}
+	}
+	// service is stopped and its last error is dropped, it happens only when notifier is closed
+	n.fail(context.Canceled)
+}
+
+// fail resolves everyone waiting with the error, notifier cannot be used anymore
+func (n *CommitNotifier) fail(err error) {
+	n.mutex.Lock()
+	n.err = err
+	waiting := n.waiting
+	n.waiting = make(map[string][]*CommitFuture)
+	n.mutex.Unlock()
+	n.setReady()
+	for _, list := range waiting {
+		for _, f := range list {
+			f.resolve(nil, err)
+		}
+	}
+}
+
+func (n *CommitNotifier) setReady() {
+	n.readyOnce.Do(func() {
+		close(n.ready)
+	})
+}
+
+func (n *CommitNotifier) remove(f *CommitFuture) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	list := n.waiting[f.TxID]
+	for i, e := range list {
+		if e == f {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(n.waiting, f.TxID)
+	} else {
+		n.waiting[f.TxID] = list
+	}
+}
+
+// Done returns channel that is closed when transaction is committed, registration times out or notifier fails.
+func (f *CommitFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result waits for commit. Error is ErrCommitTimeout, ErrCommitCancelled or the error that stopped the notifier.
+func (f *CommitFuture) Result() (*TxCommit, error) {
+	<-f.done
+	return f.commit, f.err
+}
+
+// Cancel removes registration. Result returns ErrCommitCancelled if commit was not received before.
+func (f *CommitFuture) Cancel() {
+	f.notifier.remove(f)
+	f.resolve(nil, ErrCommitCancelled)
+}
+
+func (f *CommitFuture) resolve(commit *TxCommit, err error) {
+	f.once.Do(func() {
+		if f.timer != nil {
+			f.timer.Stop()
+		}
+		f.commit = commit
+		f.err = err
+		close(f.done)
+	})
+}
@@ -0,0 +1,49 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommitNotifierRegisterWaitsForFirstBlock(t *testing.T) {
+	crypto, identity := testIdentity(t)
+
+	// peer without blocks never positions the stream, so registration cannot be trusted and times out
+	empty := startTestPeer(t, "empty", nil)
+	notifier, err := NewCommitNotifier(crypto, identity, []*Peer{empty}, "testchannel", CommitNotifierOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notifier.Close()
+	start := time.Now()
+	future := notifier.Register("tx1", 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("Register returned after %v, before first block was received", elapsed)
+	}
+	if _, err := future.Result(); err != ErrCommitTimeout {
+		t.Fatalf("expected ErrCommitTimeout, got %v", err)
+	}
+
+	// after first block registration waits for commit
+	ledger := startTestPeer(t, "ledger", testChain(t, 3))
+	notifier, err = NewCommitNotifier(crypto, identity, []*Peer{ledger}, "testchannel", CommitNotifierOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notifier.Close()
+	future = notifier.Register("tx1", 5*time.Second)
+	select {
+	case <-future.Done():
+		_, err := future.Result()
+		t.Fatalf("expected registration to wait for commit, got %v", err)
+	default:
+	}
+	future.Cancel()
+	if _, err := future.Result(); err != ErrCommitCancelled {
+		t.Fatalf("expected ErrCommitCancelled, got %v", err)
+	}
+}
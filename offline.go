@@ -0,0 +1,178 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// UnsignedProposal is transaction proposal that must be signed outside of gohfc, for example in offline signing
+// service. Sign Digest with the private key of identity used to create the proposal, and pass the signature to
+// `client.EndorseProposal`. All fields are exported, so proposal can be serialized and send to signing service.
+type UnsignedProposal struct {
+	// TxID is transaction id. It is known before proposal is signed.
+	TxID string
+	// Proposal is marshaled peer.Proposal
+	Proposal []byte
+	// Digest is hash of Proposal that must be signed. Signature must be ASN.1 DER encoded ECDSA signature.
+	Digest []byte
+}
+
+// UnsignedTransaction is endorsed transaction that must be signed outside of gohfc. Sign Digest with the same key that
+// signed the proposal and pass the signature to `client.SignTransaction`.
+type UnsignedTransaction struct {
+	// TxID is transaction id
+	TxID string
+	// Payload is marshaled common.Payload, it is the payload of envelope send to orderer
+	Payload []byte
+	// Digest is hash of Payload that must be signed
+	Digest []byte
+	// Simulation is the result of simulation. Status and Orderer are not set until transaction is submitted.
+	Simulation *InvokeResponse
+}
+
+// CreateProposal creates unsigned proposal for invoking chainCode. Only certificate and MspId of identity are used,
+// private key is not needed. Peers are the peers that will endorse the proposal, they are needed to bind proposal to
+// TLS client certificate when mutual TLS is used.
+func (c *FabricClient) CreateProposal(identity Identity, chainCode ChainCode, peers []string) (*UnsignedProposal, error) {
+	execPeers := c.getPeers(peers)
+	if len(peers) != len(execPeers) {
+		return nil, ErrPeerNameNotFound
+	}
+	tlsCertHash, err := c.tlsCertHash(execPeers)
+	if err != nil {
+		return nil, err
+	}
+	prop, err := createTransactionProposal(identity, chainCode, tlsCertHash)
+	if err != nil {
+		return nil, err
+	}
+	return &UnsignedProposal{TxID: prop.transactionId, Proposal: prop.proposal, Digest: c.Crypto.Hash(prop.proposal)}, nil
+}
+
+// EndorseProposal sends externally signed proposal to peers for endorsement and returns unsigned transaction.
+// Signature is checked against certificate in the proposal before it is send. Signatures with high S value are
+// converted to low S, because Fabric accepts only low S signatures.
+func (c *FabricClient) EndorseProposal(ctx context.Context, proposal *UnsignedProposal, signature []byte, peers []string) (*UnsignedTransaction, error) {
+	execPeers := c.getPeers(peers)
+	if len(peers) != len(execPeers) {
+		return nil, ErrPeerNameNotFound
+	}
+	prop, err := getProposal(proposal.Proposal)
+	if err != nil {
+		return nil, err
+	}
+	header, err := getHeader(prop.Header)
+	if err != nil {
+		return nil, err
+	}
+	signature, err = checkSignature(header.SignatureHeader, c.Crypto.Hash(proposal.Proposal), signature)
+	if err != nil {
+		return nil, err
+	}
+	responses := sendToPeers(ctx, execPeers, &peer.SignedProposal{ProposalBytes: proposal.Proposal, Signature: signature})
+	if err := c.verifyEndorsements(proposal.Proposal, responses); err != nil {
+		return nil, err
+	}
+	transaction, err := createTransaction(proposal.Proposal, responses)
+	if err != nil {
+		return nil, err
+	}
+	endorsed := &endorsedTransaction{txId: proposal.TxID, responses: responses}
+	return &UnsignedTransaction{
+		TxID:       proposal.TxID,
+		Payload:    transaction,
+		Digest:     c.Crypto.Hash(transaction),
+		Simulation: endorsed.invokeResponse(),
+	}, nil
+}
+
+// SignTransaction attaches externally produced signature to transaction and returns marshaled common.Envelope.
+// Envelope can be saved and submitted later, from any machine, using `client.SubmitTransaction`.
+func (c *FabricClient) SignTransaction(transaction *UnsignedTransaction, signature []byte) ([]byte, error) {
+	payload := new(common.Payload)
+	if err := proto.Unmarshal(transaction.Payload, payload); err != nil {
+		return nil, err
+	}
+	if payload.Header == nil {
+		return nil, ErrInvalidTransaction
+	}
+	signature, err := checkSignature(payload.Header.SignatureHeader, c.Crypto.Hash(transaction.Payload), signature)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&common.Envelope{Payload: transaction.Payload, Signature: signature})
+}
+
+// SubmitTransaction sends signed envelope created by `client.SignTransaction` to orderers according to policy.
+// Simulation results are not available here, so only Status, TxID, Orderer and Attempts are set in the response.
+func (c *FabricClient) SubmitTransaction(ctx context.Context, envelope []byte, policy BroadcastPolicy) (*InvokeResponse, error) {
+	if _, err := c.getOrderers(policy.Orderers); err != nil {
+		return nil, err
+	}
+	env := new(common.Envelope)
+	if err := proto.Unmarshal(envelope, env); err != nil {
+		return nil, err
+	}
+	payload := new(common.Payload)
+	if err := proto.Unmarshal(env.Payload, payload); err != nil {
+		return nil, err
+	}
+	if payload.Header == nil {
+		return nil, ErrInvalidTransaction
+	}
+	channelHeader := new(common.ChannelHeader)
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, channelHeader); err != nil {
+		return nil, err
+	}
+	reply, err := c.Broadcast(ctx, env, policy)
+	if err != nil {
+		return nil, err
+	}
+	return &InvokeResponse{Status: reply.Response.Status, TxID: channelHeader.TxId, Orderer: reply.Orderer,
+		Attempts: reply.Attempts}, nil
+}
+
+// checkSignature verifies signature of digest using certificate of creator from signature header, and converts high
+// S signature to low S.
+func checkSignature(signatureHeader []byte, digest []byte, signature []byte) ([]byte, error) {
+	sh := new(common.SignatureHeader)
+	if err := proto.Unmarshal(signatureHeader, sh); err != nil {
+		return nil, err
+	}
+	creator := new(msp.SerializedIdentity)
+	if err := proto.Unmarshal(sh.Creator, creator); err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(creator.IdBytes)
+	if block == nil {
+		return nil, ErrInvalidDataForParcelIdentity
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidKeyType
+	}
+	sig := new(eCDSASignature)
+	if _, err := asn1.Unmarshal(signature, sig); err != nil {
+		return nil, ErrInvalidSignature
+	}
+	preventMalleability(key, sig.S)
+	if !ecdsa.Verify(key, digest, sig.R, sig.S) {
+		return nil, ErrInvalidSignature
+	}
+	return asn1.Marshal(*sig)
+}
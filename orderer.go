@@ -14,37 +14,37 @@ import (
 	"github.com/golang/protobuf/proto"
 	"time"
 	"google.golang.org/grpc/keepalive"
+	"sync"
 )
 
 // Orderer expose API's to communicate with orderers.
 type Orderer struct {
-	Name   string
-	Uri    string
-	Opts   []grpc.DialOption
-	caPath string
-	con    *grpc.ClientConn
-	client orderer.AtomicBroadcastClient
+	Name string
+	Uri  string
+	Opts []grpc.DialOption
+	// TlsCertHash is SHA256 hash of the TLS client certificate used to connect to this orderer.
+	// It is populated from config when mutual TLS is used and is send in channel header for TLS binding.
+	TlsCertHash []byte
+	// PoolSize is the number of gRPC connections used to communicate with this orderer. Default is 1.
+	PoolSize int
+	// VerifyBlocks enables verification of data hash and hash chain of blocks received in every Deliver call.
+	VerifyBlocks bool
+	caPath       string
+	pool         *connectionPool
+	sessionMutex sync.Mutex
+	session      *broadcastSession
 }
 
 const timeout = 5
 
 // Broadcast Broadcast envelope to orderer for execution.
 func (o *Orderer) Broadcast(envelope *common.Envelope) (*orderer.BroadcastResponse, error) {
-	if o.con == nil {
-		c, err := grpc.Dial(o.Uri, o.Opts...)
-		if err != nil {
-			return nil, fmt.Errorf("cannot connect to orderer: %s err is: %v", o.Name, err)
-		}
-		o.con = c
-		o.client = orderer.NewAtomicBroadcastClient(o.con)
-	}
-	bcc, err := o.client.Broadcast(context.Background())
-	if err != nil {
-		return nil, err
-	}
-	defer bcc.CloseSend()
-	bcc.Send(envelope)
-	response, err := bcc.Recv()
+	return o.BroadcastContext(context.Background(), envelope)
+}
+
+// BroadcastContext is same as Broadcast, but ctx is used for dialing and for the broadcast stream.
+func (o *Orderer) BroadcastContext(ctx context.Context, envelope *common.Envelope) (*orderer.BroadcastResponse, error) {
+	response, err := o.send(ctx, envelope)
 	if err != nil {
 		return nil, err
 	}
@@ -55,16 +55,88 @@ func (o *Orderer) Broadcast(envelope *common.Envelope) (*orderer.BroadcastRespon
 	return response, err
 }
 
-// Deliver delivers envelope to orderer. Please note that new connection will be created on every call of Deliver.
+// BroadcastAsync sends envelope to orderer without waiting for the response. All async broadcasts to this orderer share
+// one long lived stream, so many envelopes can be in flight at the same time. ctx is used if new connection or stream
+// must be created, and it is checked again just before envelope is send. Once envelope is send it is on the wire and
+// cancelling ctx does not stop it, orderer response is still available from returned future.
+func (o *Orderer) BroadcastAsync(ctx context.Context, envelope *common.Envelope) (*BroadcastFuture, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s, err := o.broadcastSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.submit(ctx, envelope), nil
+}
+
+// broadcastSession returns current broadcast session or creates new one if there is no session or it is broken.
+func (o *Orderer) broadcastSession(ctx context.Context) (*broadcastSession, error) {
+	o.sessionMutex.Lock()
+	defer o.sessionMutex.Unlock()
+	if o.session != nil && !o.session.broken() {
+		return o.session, nil
+	}
+	conn, err := o.connections().get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to orderer: %s err is: %v", o.Name, err)
+	}
+	// stream lives longer than single call, so it is not bound to ctx
+	streamCtx, cancel := context.WithCancel(context.Background())
+	stream, err := orderer.NewAtomicBroadcastClient(conn).Broadcast(streamCtx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	o.session = newBroadcastSession(stream, cancel)
+	return o.session, nil
+}
+
+// send sends envelope to orderer and returns orderer response regardless of its status.
+// Error is returned only when orderer cannot be reached or stream fails.
+func (o *Orderer) send(ctx context.Context, envelope *common.Envelope) (*orderer.BroadcastResponse, error) {
+	conn, err := o.connections().get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to orderer: %s err is: %v", o.Name, err)
+	}
+	// connection is shared, so stream must be cancelled when this call returns
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	bcc, err := orderer.NewAtomicBroadcastClient(conn).Broadcast(streamCtx)
+	if err != nil {
+		return nil, err
+	}
+	defer bcc.CloseSend()
+	if err := bcc.Send(envelope); err != nil {
+		return nil, err
+	}
+	return bcc.Recv()
+}
+
+// Deliver delivers envelope to orderer.
+// If orderer does not respond in 5 seconds ErrOrdererTimeout is returned.
 func (o *Orderer) Deliver(envelope *common.Envelope) (*common.Block, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(timeout))
+	defer cancel()
+	return o.DeliverContext(ctx, envelope)
+}
 
-	connection, err := grpc.Dial(o.Uri, o.Opts...)
+// DeliverContext is same as Deliver, but instead of fixed timeout ctx is used for dialing and for the deliver stream.
+// If ctx deadline is reached ErrOrdererTimeout is returned.
+func (o *Orderer) DeliverContext(ctx context.Context, envelope *common.Envelope) (*common.Block, error) {
+
+	connection, err := o.connections().get(ctx)
 	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ErrOrdererTimeout
+		}
 		return nil, fmt.Errorf("cannot connect to orderer: %s err is: %v", o.Name, err)
 	}
-	defer connection.Close()
 
-	dk, err := orderer.NewAtomicBroadcastClient(connection).Deliver(context.Background())
+	// connection is shared, so stream must be cancelled when this call returns
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	dk, err := orderer.NewAtomicBroadcastClient(connection).Deliver(streamCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -72,35 +144,60 @@ func (o *Orderer) Deliver(envelope *common.Envelope) (*common.Block, error) {
 		return nil, err
 	}
 	var block *common.Block
-	timer := time.NewTimer(time.Second * time.Duration(timeout))
-	defer timer.Stop()
+	var verifier *BlockVerifier
+	if o.VerifyBlocks {
+		verifier = NewBlockVerifier()
+	}
 	for {
-		select {
-		case <-timer.C:
-			return nil, ErrOrdererTimeout
-		default:
-			response, err := dk.Recv()
-			if err != nil {
-				return nil, err
+		response, err := dk.Recv()
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, ErrOrdererTimeout
+			}
+			return nil, err
+		}
+		switch t := response.Type.(type) {
+		case *orderer.DeliverResponse_Status:
+			if t.Status == common.Status_SUCCESS {
+				return block, nil
+			} else {
+				return nil, fmt.Errorf("orderer response with status: %v", t.Status)
 			}
-			switch t := response.Type.(type) {
-			case *orderer.DeliverResponse_Status:
-				if t.Status == common.Status_SUCCESS {
-					return block, nil
-				} else {
-					return nil, fmt.Errorf("orderer response with status: %v", t.Status)
+		case *orderer.DeliverResponse_Block:
+			block = response.GetBlock()
+			if verifier != nil {
+				if err := verifier.Verify(block); err != nil {
+					return nil, err
 				}
-			case *orderer.DeliverResponse_Block:
-				block = response.GetBlock()
-
-			default:
-				return nil, fmt.Errorf("unknown response type from orderer: %s", t)
 			}
+
+		default:
+			return nil, fmt.Errorf("unknown response type from orderer: %s", t)
 		}
 	}
 }
 
-func (o *Orderer) getGenesisBlock(identity Identity, crypto CryptoSuite, channelId string) (*common.Block, error) {
+// Close closes all connections to this orderer. Pending async broadcasts fail with ErrConnectionClosed.
+func (o *Orderer) Close() error {
+	o.sessionMutex.Lock()
+	if o.session != nil {
+		o.session.fail(ErrConnectionClosed)
+	}
+	o.sessionMutex.Unlock()
+	return o.connections().close()
+}
+
+func (o *Orderer) connections() *connectionPool {
+	return lazyConnectionPool(&o.pool, o.Uri, o.Opts, o.PoolSize)
+}
+
+func (o *Orderer) getGenesisBlock(ctx context.Context, identity Identity, crypto CryptoSuite, channelId string) (*common.Block, error) {
+	// keep default Deliver timeout when caller does not set any deadline
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Second*time.Duration(timeout))
+		defer cancel()
+	}
 
 	seekInfo := &orderer.SeekInfo{
 		Start:    &orderer.SeekPosition{Type: &orderer.SeekPosition_Specified{Specified: &orderer.SeekSpecified{Number: 0}}},
@@ -121,7 +218,7 @@ func (o *Orderer) getGenesisBlock(identity Identity, crypto CryptoSuite, channel
 		return nil, err
 	}
 
-	headerBytes, err := channelHeader(common.HeaderType_DELIVER_SEEK_INFO, txId, channelId, 0, nil)
+	headerBytes, err := channelHeader(common.HeaderType_DELIVER_SEEK_INFO, txId, channelId, 0, nil, o.TlsCertHash)
 	signatureHeaderBytes, err := signatureHeader(creator, txId)
 	if err != nil {
 		return nil, err
@@ -136,20 +233,22 @@ func (o *Orderer) getGenesisBlock(identity Identity, crypto CryptoSuite, channel
 		return nil, err
 	}
 	env := &common.Envelope{Payload: payloadBytes, Signature: payloadSignedBytes}
-	return o.Deliver(env)
+	return o.DeliverContext(ctx, env)
 }
 
 // NewOrdererFromConfig create new Orderer from config
 func NewOrdererFromConfig(conf OrdererConfig) (*Orderer, error) {
-	o := Orderer{Uri: conf.Host, caPath: conf.TlsPath}
+	o := Orderer{Uri: conf.Host, caPath: conf.TlsPath, PoolSize: conf.PoolSize,
+		VerifyBlocks: conf.VerifyBlocks}
 	if !conf.UseTLS {
 		o.Opts = []grpc.DialOption{grpc.WithInsecure()}
-	} else if o.caPath != "" {
-		creds, err := credentials.NewClientTLSFromFile(o.caPath, "")
+	} else {
+		tlsConfig, certHash, err := newTLSConfig(o.caPath, conf.TlsClientCert, conf.TlsClientKey)
 		if err != nil {
-			return nil, fmt.Errorf("cannot read orderer %s credentials err is: %v", o.Name, err)
+			return nil, fmt.Errorf("cannot read orderer %s credentials err is: %v", o.Uri, err)
 		}
-		o.Opts = append(o.Opts, grpc.WithTransportCredentials(creds))
+		o.TlsCertHash = certHash
+		o.Opts = append(o.Opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	}
 	o.Opts = append(o.Opts,
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
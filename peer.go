@@ -16,13 +16,17 @@ import (
 
 // Peer expose API's to communicate with peer
 type Peer struct {
-	Name   string
-	Uri    string
-	MspId  string
-	Opts   []grpc.DialOption
-	caPath string
-	conn   *grpc.ClientConn
-	client peer.EndorserClient
+	Name  string
+	Uri   string
+	MspId string
+	Opts  []grpc.DialOption
+	// TlsCertHash is SHA256 hash of the TLS client certificate used to connect to this peer.
+	// It is populated from config when mutual TLS is used and is send in channel header for TLS binding.
+	TlsCertHash []byte
+	// PoolSize is the number of gRPC connections used to communicate with this peer. Default is 1.
+	PoolSize int
+	caPath   string
+	pool     *connectionPool
 }
 
 // PeerResponse is response from peer transaction request
@@ -34,17 +38,19 @@ type PeerResponse struct {
 
 // Endorse sends single transaction to single peer.
 func (p *Peer) Endorse(resp chan *PeerResponse, prop *peer.SignedProposal) {
-	if p.conn == nil {
-		conn, err := grpc.Dial(p.Uri, p.Opts...)
-		if err != nil {
-			resp <- &PeerResponse{Response: nil, Err: err, Name: p.Name}
-			return
-		}
-		p.conn = conn
-		p.client = peer.NewEndorserClient(p.conn)
+	p.EndorseContext(context.Background(), resp, prop)
+}
+
+// EndorseContext sends single transaction to single peer. ctx is used for dialing and for the proposal request, so
+// when ctx is done response with ctx error is send to resp.
+func (p *Peer) EndorseContext(ctx context.Context, resp chan *PeerResponse, prop *peer.SignedProposal) {
+	conn, err := p.connections().get(ctx)
+	if err != nil {
+		resp <- &PeerResponse{Response: nil, Err: err, Name: p.Name}
+		return
 	}
 
-	proposalResp, err := p.client.ProcessProposal(context.Background(), prop)
+	proposalResp, err := peer.NewEndorserClient(conn).ProcessProposal(ctx, prop)
 	if err != nil {
 		resp <- &PeerResponse{Response: nil, Name: p.Name, Err: err}
 		return
@@ -52,17 +58,27 @@ func (p *Peer) Endorse(resp chan *PeerResponse, prop *peer.SignedProposal) {
 	resp <- &PeerResponse{Response: proposalResp, Name: p.Name, Err: nil}
 }
 
+// Close closes all connections to this peer.
+func (p *Peer) Close() error {
+	return p.connections().close()
+}
+
+func (p *Peer) connections() *connectionPool {
+	return lazyConnectionPool(&p.pool, p.Uri, p.Opts, p.PoolSize)
+}
+
 // NewPeerFromConfig creates new peer from provided config
 func NewPeerFromConfig(conf PeerConfig) (*Peer, error) {
-	p := Peer{Uri: conf.Host, caPath: conf.TlsPath}
+	p := Peer{Uri: conf.Host, MspId: conf.MspId, caPath: conf.TlsPath, PoolSize: conf.PoolSize}
 	if !conf.UseTLS {
 		p.Opts = []grpc.DialOption{grpc.WithInsecure()}
-	} else if p.caPath != "" {
-		creds, err := credentials.NewClientTLSFromFile(p.caPath, "")
+	} else {
+		tlsConfig, certHash, err := newTLSConfig(p.caPath, conf.TlsClientCert, conf.TlsClientKey)
 		if err != nil {
-			return nil, fmt.Errorf("cannot read peer %s credentials err is: %v", p.Name, err)
+			return nil, fmt.Errorf("cannot read peer %s credentials err is: %v", p.Uri, err)
 		}
-		p.Opts = append(p.Opts, grpc.WithTransportCredentials(creds))
+		p.TlsCertHash = certHash
+		p.Opts = append(p.Opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	}
 
 	p.Opts = append(p.Opts,
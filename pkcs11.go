@@ -0,0 +1,315 @@
+//go:build pkcs11
+// +build pkcs11
+
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"github.com/miekg/pkcs11"
+	"io"
+	"math/big"
+	"sync"
+)
+
+var (
+	// named curve OIDs used in CKA_EC_PARAMS
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	oidNamedCurveP521 = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+)
+
+// PKCS11CryptSuite implements crypto suite with keys stored in hardware security module.
+// Keys are generated in the token and never leave it. Every key is identified by SKI (sha256 of public key point),
+// same as in Fabric BCCSP, so keys generated by Fabric tools can be used and the other way around.
+// Hashing and certificate requests are same as in ECCryptSuite.
+type PKCS11CryptSuite struct {
+	*ECCryptSuite
+	ctx *pkcs11.Ctx
+	// PKCS#11 sessions cannot be used concurrently, so every operation holds the mutex
+	mutex   sync.Mutex
+	session pkcs11.SessionHandle
+}
+
+// pkcs11Key is private key stored in HSM. It implements crypto.Signer, so it can be used as Identity.PrivateKey and
+// for signing certificate requests.
+type pkcs11Key struct {
+	suite  *PKCS11CryptSuite
+	handle pkcs11.ObjectHandle
+	ski    []byte
+	public *ecdsa.PublicKey
+}
+
+// NewPKCS11CryptSuiteFromConfig opens session to the token and logs in with Pin.
+// Algorithm and Hash are same as for ecdsa family.
+func NewPKCS11CryptSuiteFromConfig(config CryptoConfig) (CryptoSuite, error) {
+	ec, err := NewECCryptSuiteFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	ctx := pkcs11.New(config.Library)
+	if ctx == nil {
+		return nil, fmt.Errorf("cannot load pkcs11 library %s", config.Library)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+	suite := &PKCS11CryptSuite{ECCryptSuite: ec.(*ECCryptSuite), ctx: ctx}
+	slot, err := suite.findSlot(config.Label, config.Slot)
+	if err != nil {
+		suite.Close()
+		return nil, err
+	}
+	suite.session, err = ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		suite.Close()
+		return nil, err
+	}
+	if err := ctx.Login(suite.session, pkcs11.CKU_USER, config.Pin); err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		suite.Close()
+		return nil, err
+	}
+	return suite, nil
+}
+
+func (s *PKCS11CryptSuite) findSlot(label string, slot uint) (uint, error) {
+	slots, err := s.ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range slots {
+		if label == "" {
+			if id == slot {
+				return id, nil
+			}
+			continue
+		}
+		info, err := s.ctx.GetTokenInfo(id)
+		if err != nil {
+			return 0, err
+		}
+		if info.Label == label {
+			return id, nil
+		}
+	}
+	return 0, ErrPKCS11TokenNotFound
+}
+
+// Close logs out and releases the library. Keys from this suite cannot be used after Close.
+func (s *PKCS11CryptSuite) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.session != 0 {
+		s.ctx.Logout(s.session)
+		s.ctx.CloseSession(s.session)
+		s.session = 0
+	}
+	err := s.ctx.Finalize()
+	s.ctx.Destroy()
+	return err
+}
+
+// GenerateKey generates non exportable EC key pair in the token. CKA_ID and CKA_LABEL of both keys are set to SKI.
+func (s *PKCS11CryptSuite) GenerateKey() (interface{}, error) {
+	params, err := curveParams(s.curve)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	publicTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+	}
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+	}
+	pub, priv, err := s.ctx.GenerateKeyPair(s.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)}, publicTemplate, privateTemplate)
+	if err != nil {
+		return nil, err
+	}
+	public, err := s.publicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	ski := publicKeySKI(public)
+	idTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_ID, ski),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, fmt.Sprintf("%x", ski)),
+	}
+	if err := s.ctx.SetAttributeValue(s.session, pub, idTemplate); err != nil {
+		return nil, err
+	}
+	if err := s.ctx.SetAttributeValue(s.session, priv, idTemplate); err != nil {
+		return nil, err
+	}
+	return &pkcs11Key{suite: s, handle: priv, ski: ski, public: public}, nil
+}
+
+// KeyBySKI finds existing private key in the token. Returned key can be used as Identity.PrivateKey.
+func (s *PKCS11CryptSuite) KeyBySKI(ski []byte) (crypto.Signer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	priv, err := s.findObject(pkcs11.CKO_PRIVATE_KEY, ski)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := s.findObject(pkcs11.CKO_PUBLIC_KEY, ski)
+	if err != nil {
+		return nil, err
+	}
+	public, err := s.publicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &pkcs11Key{suite: s, handle: priv, ski: ski, public: public}, nil
+}
+
+// KeyForCertificate finds private key for certificate public key.
+func (s *PKCS11CryptSuite) KeyForCertificate(cert *x509.Certificate) (crypto.Signer, error) {
+	public, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidKeyType
+	}
+	return s.KeyBySKI(publicKeySKI(public))
+}
+
+// Sign signs message with key from the token. Other keys are signed same way as in ECCryptSuite.
+func (s *PKCS11CryptSuite) Sign(msg []byte, k interface{}) ([]byte, error) {
+	if key, ok := k.(*pkcs11Key); ok {
+		return key.Sign(nil, s.Hash(msg), s.hashOpts)
+	}
+	return s.ECCryptSuite.Sign(msg, k)
+}
+
+func (s *PKCS11CryptSuite) findObject(class uint, ski []byte) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, ski),
+	}
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return 0, err
+	}
+	objects, _, err := s.ctx.FindObjects(s.session, 1)
+	s.ctx.FindObjectsFinal(s.session)
+	if err != nil {
+		return 0, err
+	}
+	if len(objects) == 0 {
+		return 0, ErrPKCS11KeyNotFound
+	}
+	return objects[0], nil
+}
+
+// publicKey reads EC public key from the token
+func (s *PKCS11CryptSuite) publicKey(handle pkcs11.ObjectHandle) (*ecdsa.PublicKey, error) {
+	attrs, err := s.ctx.GetAttributeValue(s.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(attrs[0].Value, &oid); err != nil {
+		return nil, err
+	}
+	var curve elliptic.Curve
+	switch {
+	case oid.Equal(oidNamedCurveP256):
+		curve = elliptic.P256()
+	case oid.Equal(oidNamedCurveP384):
+		curve = elliptic.P384()
+	case oid.Equal(oidNamedCurveP521):
+		curve = elliptic.P521()
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+	// CKA_EC_POINT is DER encoded octet string with uncompressed point
+	var point []byte
+	if _, err := asn1.Unmarshal(attrs[1].Value, &point); err != nil {
+		return nil, err
+	}
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil {
+		return nil, ErrInvalidKeyType
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// Public returns public key of HSM key
+func (k *pkcs11Key) Public() crypto.PublicKey {
+	return k.public
+}
+
+// SKI returns subject key identifier of the key
+func (k *pkcs11Key) SKI() []byte {
+	return k.ski
+}
+
+// Sign signs already hashed digest in the token. Signature is ASN.1 DER encoded with low S.
+// Digest length must match opts hash, or suite hash when opts does not specify one.
+func (k *pkcs11Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s := k.suite
+	hash := s.hashOpts
+	if opts != nil && opts.HashFunc() != 0 {
+		hash = opts.HashFunc()
+	}
+	if len(digest) != hash.Size() {
+		return nil, ErrInvalidDigestLength
+	}
+	s.mutex.Lock()
+	if err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, k.handle); err != nil {
+		s.mutex.Unlock()
+		return nil, err
+	}
+	raw, err := s.ctx.Sign(s.session, digest)
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	// CKM_ECDSA returns r and s concatenated
+	half := len(raw) / 2
+	R := new(big.Int).SetBytes(raw[:half])
+	S := new(big.Int).SetBytes(raw[half:])
+	preventMalleability(k.public, S)
+	return asn1.Marshal(eCDSASignature{R, S})
+}
+
+func curveParams(curve elliptic.Curve) ([]byte, error) {
+	switch curve {
+	case elliptic.P256():
+		return asn1.Marshal(oidNamedCurveP256)
+	case elliptic.P384():
+		return asn1.Marshal(oidNamedCurveP384)
+	case elliptic.P521():
+		return asn1.Marshal(oidNamedCurveP521)
+	}
+	return nil, ErrInvalidAlgorithm
+}
+
+// publicKeySKI is sha256 of uncompressed public key point, same as in Fabric BCCSP
+func publicKeySKI(public *ecdsa.PublicKey) []byte {
+	h := sha256.Sum256(elliptic.Marshal(public.Curve, public.X, public.Y))
+	return h[:]
+}
@@ -0,0 +1,13 @@
+//go:build !pkcs11
+// +build !pkcs11
+
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+// NewPKCS11CryptSuiteFromConfig is available only when gohfc is build with `pkcs11` build tag.
+func NewPKCS11CryptSuiteFromConfig(config CryptoConfig) (CryptoSuite, error) {
+	return nil, ErrPKCS11NotSupported
+}
@@ -0,0 +1,115 @@
+//go:build pkcs11
+// +build pkcs11
+
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"os"
+	"testing"
+)
+
+// newSoftHSMSuite opens token from SoftHSM configured with SOFTHSM2_CONF. Token label, pin and library path can be
+// changed with PKCS11_LABEL, PKCS11_PIN and PKCS11_LIB.
+func newSoftHSMSuite(t *testing.T) *PKCS11CryptSuite {
+	if os.Getenv("SOFTHSM2_CONF") == "" {
+		t.Skip("SOFTHSM2_CONF is not set")
+	}
+	config := CryptoConfig{
+		Family:    "pkcs11",
+		Algorithm: "P256-SHA256",
+		Hash:      "SHA2-256",
+		Library:   envOr("PKCS11_LIB", "/usr/lib/softhsm/libsofthsm2.so"),
+		Label:     envOr("PKCS11_LABEL", "gohfc"),
+		Pin:       envOr("PKCS11_PIN", "98765432"),
+	}
+	suite, err := NewPKCS11CryptSuiteFromConfig(config)
+	if err != nil {
+		t.Fatalf("cannot open token: %v", err)
+	}
+	return suite.(*PKCS11CryptSuite)
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func verifySignature(t *testing.T, suite *PKCS11CryptSuite, key crypto.Signer, msg, signature []byte) {
+	sig := new(eCDSASignature)
+	if _, err := asn1.Unmarshal(signature, sig); err != nil {
+		t.Fatalf("signature is not ASN.1: %v", err)
+	}
+	public := key.Public().(*ecdsa.PublicKey)
+	if !ecdsa.Verify(public, suite.Hash(msg), sig.R, sig.S) {
+		t.Fatal("signature is not valid")
+	}
+	if sig.S.Cmp(ecCurveHalfOrders[public.Curve]) > 0 {
+		t.Fatal("signature S is not low")
+	}
+}
+
+func TestPKCS11SignAndVerify(t *testing.T) {
+	suite := newSoftHSMSuite(t)
+	defer suite.Close()
+
+	k, err := suite.GenerateKey()
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	key := k.(*pkcs11Key)
+	msg := []byte("gohfc pkcs11 test")
+	signature, err := suite.Sign(msg, key)
+	if err != nil {
+		t.Fatalf("cannot sign: %v", err)
+	}
+	verifySignature(t, suite, key, msg, signature)
+
+	found, err := suite.KeyBySKI(key.SKI())
+	if err != nil {
+		t.Fatalf("cannot find key by SKI: %v", err)
+	}
+	public := found.Public().(*ecdsa.PublicKey)
+	if public.X.Cmp(key.public.X) != 0 || public.Y.Cmp(key.public.Y) != 0 {
+		t.Fatal("found key has different public key")
+	}
+	signature, err = suite.Sign(msg, found)
+	if err != nil {
+		t.Fatalf("cannot sign with found key: %v", err)
+	}
+	verifySignature(t, suite, found, msg, signature)
+}
+
+func TestPKCS11SignRejectsWrongDigest(t *testing.T) {
+	suite := newSoftHSMSuite(t)
+	defer suite.Close()
+
+	k, err := suite.GenerateKey()
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	key := k.(*pkcs11Key)
+	if _, err := key.Sign(nil, make([]byte, 20), crypto.SHA256); err != ErrInvalidDigestLength {
+		t.Fatalf("expected ErrInvalidDigestLength, got %v", err)
+	}
+	if _, err := key.Sign(nil, make([]byte, 48), nil); err != ErrInvalidDigestLength {
+		t.Fatalf("expected ErrInvalidDigestLength for suite hash, got %v", err)
+	}
+}
+
+func TestPKCS11KeyNotFound(t *testing.T) {
+	suite := newSoftHSMSuite(t)
+	defer suite.Close()
+
+	if _, err := suite.KeyBySKI([]byte("missing")); err != ErrPKCS11KeyNotFound {
+		t.Fatalf("expected ErrPKCS11KeyNotFound, got %v", err)
+	}
+}
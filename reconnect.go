@@ -0,0 +1,249 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ReconnectOptions define how ReconnectingListener reconnects and where it keeps its position.
+type ReconnectOptions struct {
+	// Store persists the last processed block. Default is in memory store, so position survives reconnects but not
+	// restarts. Use FileCheckpointStore to continue after restart.
+	Store CheckpointStore
+	// Key is the key of checkpoint in Store. Default is channel id.
+	Key string
+	// StartFromOldest starts from the first block when there is no checkpoint. By default listener starts from the
+	// newest block.
+	StartFromOldest bool
+	// BackoffPolicy is the delay between reconnects. Attempt that receives at least one block starts it again.
+	BackoffPolicy
+	// ManualCheckpoint disables saving checkpoint as soon as block is read from response channel. Call
+	// `listener.Checkpoint` after block is processed instead, so crash in the middle of processing does not skip
+	// the block.
+	ManualCheckpoint bool
+	// FullBlock returns raw block bytes, same as EventListener.FullBlock
+	FullBlock bool
+	// VerifyBlocks verifies hash chain of full blocks. Chain is continued across reconnects.
+	VerifyBlocks bool
+}
+
+// CheckpointError is returned when checkpoint cannot be loaded or saved. Listener stops in this case, because it
+// cannot guarantee that blocks are not skipped or processed twice.
+type CheckpointError struct {
+	Key string
+	Err error
+}
+
+func (e *CheckpointError) Error() string {
+	return fmt.Sprintf("checkpoint %s cannot be stored: %v", e.Key, e.Err)
+}
+
+// Unwrap returns error from the store
+func (e *CheckpointError) Unwrap() error {
+	return e.Err
+}
+
+// ReconnectingListener is event listener that survives broken deliver streams. When stream fails it reconnects with
+// backoff and continues from the block after the last one it delivered. Last processed block is saved in checkpoint
+// store, so restarted service can continue from the same place.
+// Only fatal errors are send to response channel: ctx cancellation, block verification and checkpoint errors.
+// After fatal error listener stops and response channel is closed.
+type ReconnectingListener struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	crypto       CryptoSuite
+	identity     Identity
+	peer         Peer
+	channelId    string
+	listenerType int
+	options      ReconnectOptions
+	verifier     *BlockVerifier
+	mutex        sync.Mutex
+	// next is the next block to request, it is valid only when positioned is true
+	next       uint64
+	positioned bool
+}
+
+// NewReconnectingListener creates listener for channel on peer p. Nothing is send to peer until `Listen` is called.
+func NewReconnectingListener(ctx context.Context, crypto CryptoSuite, identity Identity, p Peer, channelId string,
+	listenerType int, options ReconnectOptions) (*ReconnectingListener, error) {
+	if crypto == nil {
+		return nil, fmt.Errorf("cryptoSuite cannot be nil")
+	}
+	if err := options.setDefaults(channelId, listenerType); err != nil {
+		return nil, err
+	}
+	next, positioned, err := options.startPosition()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r := &ReconnectingListener{
+		ctx:          ctx,
+		cancel:       cancel,
+		crypto:       crypto,
+		identity:     identity,
+		peer:         p,
+		channelId:    channelId,
+		listenerType: listenerType,
+		options:      options,
+		next:         next,
+		positioned:   positioned,
+	}
+	if options.VerifyBlocks {
+		r.verifier = NewBlockVerifier()
+	}
+	return r, nil
+}
+
+// setDefaults validates options and fills default values
+func (o *ReconnectOptions) setDefaults(channelId string, listenerType int) error {
+	if listenerType != EventTypeFullBlock && listenerType != EventTypeFiltered {
+		return fmt.Errorf("invalid listener type provided")
+	}
+	if o.VerifyBlocks && listenerType != EventTypeFullBlock {
+		return ErrFilteredBlockNotVerifiable
+	}
+	if o.Store == nil {
+		o.Store = NewMemoryCheckpointStore()
+	}
+	if o.Key == "" {
+		o.Key = channelId
+	}
+	return nil
+}
+
+// startPosition returns the first block to request. positioned is false when listener must start from newest block.
+func (o *ReconnectOptions) startPosition() (next uint64, positioned bool, err error) {
+	last, ok, err := o.Store.Load(o.Key)
+	if err != nil {
+		return 0, false, &CheckpointError{Key: o.Key, Err: err}
+	}
+	if ok {
+		return last + 1, true, nil
+	}
+	return 0, o.StartFromOldest, nil
+}
+
+// Listen starts listening in new goroutine. Blocks are send to response in order, without gaps and duplicates.
+// Response is closed when listener stops. The last, error response is dropped if ctx is done and nobody reads it.
+func (r *ReconnectingListener) Listen(response chan<- EventBlockResponse) {
+	go r.run(response)
+}
+
+// Stop stops the listener, response is closed after that
+func (r *ReconnectingListener) Stop() {
+	r.cancel()
+}
+
+// Checkpoint saves block as the last processed block. It is needed only with ManualCheckpoint option.
+func (r *ReconnectingListener) Checkpoint(blockNumber uint64) error {
+	if err := r.options.Store.Save(r.options.Key, blockNumber); err != nil {
+		return &CheckpointError{Key: r.options.Key, Err: err}
+	}
+	return nil
+}
+
+func (r *ReconnectingListener) run(response chan<- EventBlockResponse) {
+	defer r.cancel()
+	backoff := r.options.newBackoff()
+	for {
+		progress, err := r.listenOnce(response)
+		if r.ctx.Err() != nil {
+			finishListener(r.ctx, response, r.ctx.Err())
+			return
+		}
+		if fatalListenerError(err) {
+			finishListener(r.ctx, response, err)
+			return
+		}
+		if progress {
+			backoff.reset()
+		}
+		select {
+		case <-time.After(jitter(backoff.next())):
+		case <-r.ctx.Done():
+			finishListener(r.ctx, response, r.ctx.Err())
+			return
+		}
+	}
+}
+
+// listenOnce connects to peer and forwards blocks until stream fails. progress is true if at least one block is
+// delivered.
+func (r *ReconnectingListener) listenOnce(response chan<- EventBlockResponse) (progress bool, err error) {
+	listener, err := NewEventListener(r.ctx, r.crypto, r.identity, r.peer, r.channelId, r.listenerType)
+	if err != nil {
+		return false, err
+	}
+	defer listener.Close()
+	listener.FullBlock = r.options.FullBlock
+	listener.BlockVerifier = r.verifier
+
+	r.mutex.Lock()
+	next, positioned := r.next, r.positioned
+	r.mutex.Unlock()
+	if positioned {
+		err = listener.SeekRange(next, math.MaxUint64)
+	} else {
+		err = listener.SeekNewest()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ch := make(chan EventBlockResponse)
+	listener.Listen(ch)
+	for block := range ch {
+		if block.Error != nil {
+			return progress, block.Error
+		}
+		select {
+		case response <- block:
+		case <-r.ctx.Done():
+			return progress, r.ctx.Err()
+		}
+		progress = true
+		r.mutex.Lock()
+		r.next = block.BlockHeight + 1
+		r.positioned = true
+		r.mutex.Unlock()
+		if !r.options.ManualCheckpoint {
+			if err := r.Checkpoint(block.BlockHeight); err != nil {
+				return progress, err
+			}
+		}
+	}
+	return progress, listener.Err()
+}
+
+// finishListener sends the last, error response and closes response. If ctx is done the error is send only when
+// consumer is already waiting for it, so goroutine never blocks on consumer that stopped reading.
+func finishListener(ctx context.Context, response chan<- EventBlockResponse, err error) {
+	last := EventBlockResponse{Error: err}
+	select {
+	case response <- last:
+	default:
+		select {
+		case response <- last:
+		case <-ctx.Done():
+		}
+	}
+	close(response)
+}
+
+// fatalListenerError reports errors that will not be fixed by reconnecting
+func fatalListenerError(err error) bool {
+	switch err.(type) {
+	case *BlockVerificationError, *BlockDecodeError, *CheckpointError:
+		return true
+	}
+	return err == ErrFilteredBlockNotVerifiable
+}
@@ -0,0 +1,220 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/ledger/rwset"
+	"github.com/hyperledger/fabric/protos/ledger/rwset/kvrwset"
+	"strings"
+	"unicode/utf8"
+)
+
+// compositeKeyDelimiter separates object type and attributes in composite keys created by chaincode shim
+// `CreateCompositeKey`. Composite keys also start with it, so they never collide with simple keys.
+const compositeKeyDelimiter = "\x00"
+
+// maxUnicodeRune is not allowed in composite key attributes, same as in chaincode shim
+const maxUnicodeRune = utf8.MaxRune
+
+// ReadWriteSet is decoded read-write set produced by chaincode simulation (rwset.TxReadWriteSet).
+type ReadWriteSet struct {
+	Namespaces []*NamespaceReadWriteSet
+}
+
+// NamespaceReadWriteSet holds reads and writes of single namespace (chaincode).
+type NamespaceReadWriteSet struct {
+	Namespace    string
+	Reads        []*KVRead
+	RangeQueries []*RangeQuery
+	Writes       []*KVWrite
+	// Collections are hashes of private data read and written in this namespace
+	Collections []*CollectionHashedReadWriteSet
+}
+
+// Version is ledger height at which key was last written
+type Version struct {
+	BlockNum uint64
+	TxNum    uint64
+}
+
+// KVRead is key read by chaincode. Version is nil if key did not exist.
+type KVRead struct {
+	Key     string
+	Version *Version
+}
+
+// KVWrite is key written or deleted by chaincode
+type KVWrite struct {
+	Key      string
+	IsDelete bool
+	Value    []byte
+}
+
+// RangeQuery is range query (or partial composite key query) done by chaincode.
+// Depending on number of results, peer records either all Reads or only MerkleSummary of them.
+type RangeQuery struct {
+	StartKey  string
+	EndKey    string
+	Exhausted bool
+	Reads     []*KVRead
+	// MerkleSummary is set instead of Reads when range query returned many results
+	MerkleSummary *MerkleSummary
+}
+
+// MerkleSummary is summary of range query results when there are too many of them to store
+type MerkleSummary struct {
+	MaxDegree      uint32
+	MaxLevel       uint32
+	MaxLevelHashes [][]byte
+}
+
+// CollectionHashedReadWriteSet is public part of private data read-write set. Only hashes of keys and values are
+// stored in the block, private data itself is distributed only to peers that are members of the collection.
+type CollectionHashedReadWriteSet struct {
+	CollectionName string
+	// PvtRwSetHash is hash of the whole private read-write set of this collection
+	PvtRwSetHash []byte
+	HashedReads  []*KVReadHash
+	HashedWrites []*KVWriteHash
+}
+
+// KVReadHash is hash of private key read by chaincode. Version is nil if key did not exist.
+type KVReadHash struct {
+	KeyHash []byte
+	Version *Version
+}
+
+// KVWriteHash is hash of private key and value written or deleted by chaincode
+type KVWriteHash struct {
+	KeyHash   []byte
+	IsDelete  bool
+	ValueHash []byte
+}
+
+// DecodeReadWriteSet decodes marshaled rwset.TxReadWriteSet, the `Results` field of chaincode action.
+func DecodeReadWriteSet(data []byte) (*ReadWriteSet, error) {
+	txRwSet := new(rwset.TxReadWriteSet)
+	if err := proto.Unmarshal(data, txRwSet); err != nil {
+		return nil, err
+	}
+	result := &ReadWriteSet{Namespaces: make([]*NamespaceReadWriteSet, 0, len(txRwSet.NsRwset))}
+	for _, ns := range txRwSet.NsRwset {
+		kv := new(kvrwset.KVRWSet)
+		if err := proto.Unmarshal(ns.Rwset, kv); err != nil {
+			return nil, err
+		}
+		set := &NamespaceReadWriteSet{
+			Namespace:    ns.Namespace,
+			Reads:        decodeReads(kv.Reads),
+			RangeQueries: make([]*RangeQuery, 0, len(kv.RangeQueriesInfo)),
+			Writes:       make([]*KVWrite, 0, len(kv.Writes)),
+			Collections:  make([]*CollectionHashedReadWriteSet, 0, len(ns.CollectionHashedRwset)),
+		}
+		for _, w := range kv.Writes {
+			set.Writes = append(set.Writes, &KVWrite{Key: w.Key, IsDelete: w.IsDelete, Value: w.Value})
+		}
+		for _, rq := range kv.RangeQueriesInfo {
+			query := &RangeQuery{StartKey: rq.StartKey, EndKey: rq.EndKey, Exhausted: rq.ItrExhausted}
+			if raw := rq.GetRawReads(); raw != nil {
+				query.Reads = decodeReads(raw.KvReads)
+			}
+			if merkle := rq.GetReadsMerkleHashes(); merkle != nil {
+				query.MerkleSummary = &MerkleSummary{MaxDegree: merkle.MaxDegree, MaxLevel: merkle.MaxLevel,
+					MaxLevelHashes: merkle.MaxLevelHashes}
+			}
+			set.RangeQueries = append(set.RangeQueries, query)
+		}
+		for _, c := range ns.CollectionHashedRwset {
+			collection, err := decodeCollectionHashedRwSet(c)
+			if err != nil {
+				return nil, err
+			}
+			set.Collections = append(set.Collections, collection)
+		}
+		result.Namespaces = append(result.Namespaces, set)
+	}
+	return result, nil
+}
+
+func decodeCollectionHashedRwSet(c *rwset.CollectionHashedReadWriteSet) (*CollectionHashedReadWriteSet, error) {
+	hashed := new(kvrwset.HashedRWSet)
+	if err := proto.Unmarshal(c.HashedRwset, hashed); err != nil {
+		return nil, err
+	}
+	result := &CollectionHashedReadWriteSet{
+		CollectionName: c.CollectionName,
+		PvtRwSetHash:   c.PvtRwsetHash,
+		HashedReads:    make([]*KVReadHash, 0, len(hashed.HashedReads)),
+		HashedWrites:   make([]*KVWriteHash, 0, len(hashed.HashedWrites)),
+	}
+	for _, r := range hashed.HashedReads {
+		result.HashedReads = append(result.HashedReads, &KVReadHash{KeyHash: r.KeyHash, Version: decodeVersion(r.Version)})
+	}
+	for _, w := range hashed.HashedWrites {
+		result.HashedWrites = append(result.HashedWrites, &KVWriteHash{KeyHash: w.KeyHash, IsDelete: w.IsDelete,
+			ValueHash: w.ValueHash})
+	}
+	return result, nil
+}
+
+func decodeReads(reads []*kvrwset.KVRead) []*KVRead {
+	result := make([]*KVRead, 0, len(reads))
+	for _, r := range reads {
+		result = append(result, &KVRead{Key: r.Key, Version: decodeVersion(r.Version)})
+	}
+	return result
+}
+
+func decodeVersion(v *kvrwset.Version) *Version {
+	if v == nil {
+		return nil
+	}
+	return &Version{BlockNum: v.BlockNum, TxNum: v.TxNum}
+}
+
+// IsCompositeKey returns true if key is created by chaincode shim `CreateCompositeKey`
+func IsCompositeKey(key string) bool {
+	return strings.HasPrefix(key, compositeKeyDelimiter)
+}
+
+// SplitCompositeKey splits composite key to object type and attributes, same as chaincode shim `SplitCompositeKey`.
+// ErrInvalidCompositeKey is returned for simple keys.
+func SplitCompositeKey(key string) (string, []string, error) {
+	if len(key) < 2 || !IsCompositeKey(key) || !strings.HasSuffix(key, compositeKeyDelimiter) {
+		return "", nil, ErrInvalidCompositeKey
+	}
+	parts := strings.Split(key[1:len(key)-1], compositeKeyDelimiter)
+	return parts[0], parts[1:], nil
+}
+
+// CreateCompositeKey creates composite key same way as chaincode shim, so it can be compared with keys in read-write
+// sets. Same as in shim, object type and attributes must be valid utf8 and cannot contain U+0000 (the delimiter) or
+// U+10FFFF, otherwise ErrInvalidCompositeKeyAttribute is returned.
+func CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	if err := validateCompositeKeyAttribute(objectType); err != nil {
+		return "", err
+	}
+	key := compositeKeyDelimiter + objectType + compositeKeyDelimiter
+	for _, a := range attributes {
+		if err := validateCompositeKeyAttribute(a); err != nil {
+			return "", err
+		}
+		key += a + compositeKeyDelimiter
+	}
+	return key, nil
+}
+
+func validateCompositeKeyAttribute(s string) error {
+	if !utf8.ValidString(s) {
+		return ErrInvalidCompositeKeyAttribute
+	}
+	for _, r := range s {
+		if r == 0 || r == maxUnicodeRune {
+			return ErrInvalidCompositeKeyAttribute
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompositeKeyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		objectType string
+		attributes []string
+		key        string
+	}{
+		{"no attributes", "asset", nil, "\x00asset\x00"},
+		{"empty attribute list", "asset", []string{}, "\x00asset\x00"},
+		{"attributes", "owner~asset", []string{"alice", "car1"}, "\x00owner~asset\x00alice\x00car1\x00"},
+		{"empty attribute", "asset", []string{"", "x"}, "\x00asset\x00\x00x\x00"},
+		{"empty object type", "", []string{"a"}, "\x00\x00a\x00"},
+		{"unicode", "资产", []string{"ключ", "\U0010FFFE"}, "\x00资产\x00ключ\x00\U0010FFFE\x00"},
+	}
+	for _, test := range tests {
+		key, err := CreateCompositeKey(test.objectType, test.attributes)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if key != test.key {
+			t.Errorf("%s: expected key %q, got %q", test.name, test.key, key)
+		}
+		if !IsCompositeKey(key) {
+			t.Errorf("%s: %q is not recognized as composite key", test.name, key)
+		}
+		objectType, attributes, err := SplitCompositeKey(key)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if objectType != test.objectType {
+			t.Errorf("%s: expected object type %q, got %q", test.name, test.objectType, objectType)
+		}
+		if len(attributes) != len(test.attributes) || (len(attributes) > 0 && !reflect.DeepEqual(attributes, test.attributes)) {
+			t.Errorf("%s: expected attributes %q, got %q", test.name, test.attributes, attributes)
+		}
+	}
+}
+
+func TestCreateCompositeKeyInvalidAttribute(t *testing.T) {
+	tests := []struct {
+		name       string
+		objectType string
+		attributes []string
+	}{
+		{"min rune in attribute", "asset", []string{"a\x00b"}},
+		{"min rune as attribute", "asset", []string{"\x00"}},
+		{"min rune in object type", "as\x00set", nil},
+		{"max rune", "asset", []string{"\U0010FFFF"}},
+		{"invalid utf8", "asset", []string{"\xff"}},
+	}
+	for _, test := range tests {
+		if _, err := CreateCompositeKey(test.objectType, test.attributes); err != ErrInvalidCompositeKeyAttribute {
+			t.Errorf("%s: expected ErrInvalidCompositeKeyAttribute, got %v", test.name, err)
+		}
+	}
+}
+
+func TestSplitCompositeKeyNotComposite(t *testing.T) {
+	for _, key := range []string{"", "asset", "asset\x00a\x00"} {
+		if IsCompositeKey(key) {
+			t.Errorf("%q must not be composite key", key)
+		}
+		if _, _, err := SplitCompositeKey(key); err != ErrInvalidCompositeKey {
+			t.Errorf("%q: expected ErrInvalidCompositeKey, got %v", key, err)
+		}
+	}
+	// keys that start with delimiter but are not complete
+	for _, key := range []string{"\x00", "\x00asset"} {
+		if _, _, err := SplitCompositeKey(key); err != ErrInvalidCompositeKey {
+			t.Errorf("%q: expected ErrInvalidCompositeKey, got %v", key, err)
+		}
+	}
+}
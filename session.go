@@ -0,0 +1,138 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+	"sync"
+)
+
+// BroadcastFuture is the result of asynchronous broadcast to orderer.
+// Result is available after Done channel is closed.
+type BroadcastFuture struct {
+	done     chan struct{}
+	response *orderer.BroadcastResponse
+	err      error
+}
+
+// Done returns channel that is closed when orderer responds or when broadcast fails.
+func (f *BroadcastFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result waits for orderer response. Error is returned when orderer response is not SUCCESS, or when stream fails
+// before response is received. In the second case it is unknown is transaction accepted by orderer or not.
+func (f *BroadcastFuture) Result() (*orderer.BroadcastResponse, error) {
+	<-f.done
+	return f.response, f.err
+}
+
+func newBroadcastFuture() *BroadcastFuture {
+	return &BroadcastFuture{done: make(chan struct{})}
+}
+
+func (f *BroadcastFuture) resolve(response *orderer.BroadcastResponse, err error) {
+	if err == nil && response.Status != common.Status_SUCCESS {
+		err = fmt.Errorf("unexpected status: %v", response.Status)
+		response = nil
+	}
+	f.response = response
+	f.err = err
+	close(f.done)
+}
+
+// broadcastSession is long lived Broadcast stream to single orderer.
+// Envelopes are send one after another without waiting for responses. Orderer responds in the same order as
+// envelopes are received, so responses are matched to submissions using FIFO queue.
+// When stream fails all pending submissions fail and session cannot be used anymore.
+type broadcastSession struct {
+	stream     orderer.AtomicBroadcast_BroadcastClient
+	cancel     context.CancelFunc
+	sendMutex  sync.Mutex
+	queueMutex sync.Mutex
+	pending    []*BroadcastFuture
+	err        error
+}
+
+func newBroadcastSession(stream orderer.AtomicBroadcast_BroadcastClient, cancel context.CancelFunc) *broadcastSession {
+	s := &broadcastSession{stream: stream, cancel: cancel}
+	go s.receive()
+	return s
+}
+
+// submit sends envelope to orderer and returns future for orderer response.
+// Send and enqueue are done under sendMutex, so order in queue is the same as order in stream.
+// Receiving is guarded only by queueMutex, so blocked Send never stops responses from being processed.
+// If ctx is done while waiting for other senders, envelope is not send and future fails with ctx error.
+func (s *broadcastSession) submit(ctx context.Context, envelope *common.Envelope) *BroadcastFuture {
+	f := newBroadcastFuture()
+	s.sendMutex.Lock()
+	defer s.sendMutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		f.resolve(nil, err)
+		return f
+	}
+
+	s.queueMutex.Lock()
+	if s.err != nil {
+		err := s.err
+		s.queueMutex.Unlock()
+		f.resolve(nil, err)
+		return f
+	}
+	s.pending = append(s.pending, f)
+	s.queueMutex.Unlock()
+
+	if err := s.stream.Send(envelope); err != nil {
+		s.fail(err)
+	}
+	return f
+}
+
+func (s *broadcastSession) receive() {
+	for {
+		response, err := s.stream.Recv()
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		s.queueMutex.Lock()
+		if len(s.pending) == 0 {
+			s.queueMutex.Unlock()
+			s.fail(fmt.Errorf("orderer response without matching request"))
+			return
+		}
+		f := s.pending[0]
+		s.pending[0] = nil
+		s.pending = s.pending[1:]
+		s.queueMutex.Unlock()
+		f.resolve(response, nil)
+	}
+}
+
+// fail marks session as broken, closes the stream and fails all pending submissions with err.
+func (s *broadcastSession) fail(err error) {
+	s.queueMutex.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	pending := s.pending
+	s.pending = nil
+	s.queueMutex.Unlock()
+
+	s.cancel()
+	for _, f := range pending {
+		f.resolve(nil, err)
+	}
+}
+
+func (s *broadcastSession) broken() bool {
+	s.queueMutex.Lock()
+	defer s.queueMutex.Unlock()
+	return s.err != nil
+}
@@ -0,0 +1,45 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// newTLSConfig creates tls.Config from server root CA and optional client certificate and key.
+// If caPath is empty system roots are used for server verification.
+// When client certificate is provided its SHA256 hash is returned. This hash is what Fabric expect in
+// `ChannelHeader.TlsCertHash` when mutual TLS is enabled in peers and orderers.
+func newTLSConfig(caPath, certPath, keyPath string) (*tls.Config, []byte, error) {
+	config := new(tls.Config)
+	if caPath != "" {
+		caPem, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, nil, fmt.Errorf("no valid certificates found in: %s", caPath)
+		}
+		config.RootCAs = pool
+	}
+	if certPath == "" && keyPath == "" {
+		return config, nil, nil
+	}
+	if certPath == "" || keyPath == "" {
+		return nil, nil, ErrTLSClientKeyPairIncomplete
+	}
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	config.Certificates = []tls.Certificate{cert}
+	hash := sha256.Sum256(cert.Certificate[0])
+	return config, hash[:], nil
+}
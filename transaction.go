@@ -16,6 +16,7 @@ import (
 	"time"
 	"github.com/hyperledger/fabric/protos/peer"
 	"bytes"
+	"context"
 )
 
 // TransactionId represents transaction identifier. TransactionId is the unique transaction number.
@@ -38,14 +39,128 @@ type InvokeResponse struct {
 	Status common.Status
 	// TxID is transaction id. This id can be used to track transactions and their status
 	TxID string
+	// Orderer is the name of the orderer that accepted the transaction
+	Orderer string
+	// Attempts holds every attempt to send transaction to orderers
+	Attempts []*BroadcastAttempt
+	// Payload is the value returned by chaincode during simulation (`shim.Success(payload)`)
+	Payload []byte
+	// Message is the status message returned by chaincode during simulation
+	Message string
+	// Event is the chaincode event set during simulation. It is nil if chaincode did not set event.
+	// Event will be emitted only if transaction is committed as valid.
+	Event *peer.ChaincodeEvent
+	// Endorsements holds response from every peer that was asked to endorse the transaction
+	Endorsements []*EndorsementSummary
+}
+
+// EndorsementSummary is the response from single endorsing peer
+type EndorsementSummary struct {
+	Peer string
+	// MspId is MSP of the identity that signed the endorsement
+	MspId string
+	// Status and Message are returned by chaincode on this peer
+	Status  int32
+	Message string
+	// Err is not nil if peer did not return the response
+	Err error
+	// Included reports is this endorsement included in the transaction
+	Included bool
+	// Reason explains why endorsement is not included. It is peer error, ErrBadTransactionStatus,
+	// *EndorsementVerificationError, ErrEndorsementsDoNotMatch or ErrEndorsementCancelled. Check mismatch with
+	// errors.Is(err, ErrEndorsementsDoNotMatch), because invoke returns it wrapped in *EndorsementMismatchError.
+	Reason error
+	// Straggler is true if peer did not respond before quorum was reached and its call was cancelled
+	Straggler bool
+}
+
+// endorsedTransaction is signed transaction envelope ready to be send to orderer together with simulation results.
+type endorsedTransaction struct {
+	envelope  *common.Envelope
+	txId      string
+	responses []*PeerResponse
+	// dropped holds reasons for responses that are not included in the transaction
+	dropped map[*PeerResponse]error
+}
+
+// invokeResponse creates response with simulation results. Payload, message and event are taken from first
+// included endorsement, they are same in all endorsements used in transaction.
+func (t *endorsedTransaction) invokeResponse() *InvokeResponse {
+	response := &InvokeResponse{TxID: t.txId, Endorsements: make([]*EndorsementSummary, 0, len(t.responses))}
+	var endorsed *peer.ProposalResponse
+	for _, r := range t.responses {
+		summary := &EndorsementSummary{Peer: r.Name, Err: r.Err, Reason: t.dropped[r]}
+		summary.Included = summary.Reason == nil && responseError(r) == nil
+		summary.Straggler = summary.Reason == ErrEndorsementCancelled
+		if r.Err == nil {
+			summary.Status = r.Response.GetResponse().GetStatus()
+			summary.Message = r.Response.GetResponse().GetMessage()
+			endorser := new(msp.SerializedIdentity)
+			if err := proto.Unmarshal(r.Response.GetEndorsement().GetEndorser(), endorser); err == nil {
+				summary.MspId = endorser.Mspid
+			}
+			if endorsed == nil && summary.Included {
+				endorsed = r.Response
+			}
+		}
+		response.Endorsements = append(response.Endorsements, summary)
+	}
+	if endorsed == nil {
+		return response
+	}
+	response.Payload = endorsed.Response.Payload
+	response.Message = endorsed.Response.Message
+	responsePayload := new(peer.ProposalResponsePayload)
+	if err := proto.Unmarshal(endorsed.Payload, responsePayload); err != nil {
+		return response
+	}
+	action := new(peer.ChaincodeAction)
+	if err := proto.Unmarshal(responsePayload.Extension, action); err != nil || len(action.Events) == 0 {
+		return response
+	}
+	event := new(peer.ChaincodeEvent)
+	if err := proto.Unmarshal(action.Events, event); err == nil {
+		response.Event = event
+	}
+	return response
+}
+
+// InvokeFuture is the result of `client.InvokeAsync`. Transaction is already endorsed and send to orderer, and
+// orderer response is available after Done channel is closed.
+type InvokeFuture struct {
+	// TxID is transaction id. This id can be used to track transactions and their status
+	TxID string
+	// Orderer is the name of the orderer where transaction was send
+	Orderer    string
+	broadcast  *BroadcastFuture
+	simulation *InvokeResponse
+}
+
+// Done returns channel that is closed when orderer responds or when sending fails.
+func (f *InvokeFuture) Done() <-chan struct{} {
+	return f.broadcast.Done()
+}
+
+// Result waits for orderer response and returns the same result as `client.Invoke`.
+func (f *InvokeFuture) Result() (*InvokeResponse, error) {
+	reply, err := f.broadcast.Result()
+	if err != nil {
+		return nil, err
+	}
+	response := *f.simulation
+	response.Status = reply.Status
+	response.Orderer = f.Orderer
+	return &response, nil
 }
 
 // QueryTransactionResponse holds data from `client.QueryTransaction`
-// TODO it is not fully implemented!
 type QueryTransactionResponse struct {
-	PeerName   string
-	Error      error
+	PeerName string
+	Error    error
+	// StatusCode is transaction validation code, same as Transaction.ValidationCode
 	StatusCode int32
+	// Transaction is decoded transaction
+	Transaction *Transaction
 }
 
 type transactionProposal struct {
@@ -87,7 +202,9 @@ func header(signatureHeader, channelHeader []byte) (*common.Header) {
 	return header
 }
 
-func channelHeader(headerType common.HeaderType, tx *TransactionId, channelId string, epoch uint64, extension *peer.ChaincodeHeaderExtension) ([]byte, error) {
+// channelHeader creates and marshal new channel header. tlsCertHash is the hash of TLS client certificate and must be
+// provided when mutual TLS is used, otherwise it can be nil.
+func channelHeader(headerType common.HeaderType, tx *TransactionId, channelId string, epoch uint64, extension *peer.ChaincodeHeaderExtension, tlsCertHash []byte) ([]byte, error) {
 	ts, err := ptypes.TimestampProto(time.Now())
 	if err != nil {
 		return nil, err
@@ -98,11 +215,12 @@ func channelHeader(headerType common.HeaderType, tx *TransactionId, channelId st
 		channelName = channelId
 	}
 	payloadChannelHeader := &common.ChannelHeader{
-		Type:      int32(headerType),
-		Version:   1,
-		Timestamp: ts,
-		ChannelId: channelName,
-		Epoch:     epoch,
+		Type:        int32(headerType),
+		Version:     1,
+		Timestamp:   ts,
+		ChannelId:   channelName,
+		Epoch:       epoch,
+		TlsCertHash: tlsCertHash,
 	}
 	payloadChannelHeader.TxId = tx.TransactionId
 	if extension != nil {
@@ -187,14 +305,15 @@ func signedProposal(prop []byte, identity Identity, crypt CryptoSuite) (*peer.Si
 }
 
 // sendToPeers send proposal to all peers in the list for endorsement asynchronously and wait for there response.
-// there is no difference in what order results will e returned and is `p.Endorse()` guarantee that there will be
-// response, so no need of complex synchronisation and wait groups
-func sendToPeers(peers []*Peer, prop *peer.SignedProposal) []*PeerResponse {
+// there is no difference in what order results will e returned and is `p.EndorseContext()` guarantee that there will be
+// response, so no need of complex synchronisation and wait groups. When ctx is done peers that did not respond
+// return ctx error, so this function never blocks longer than ctx allows.
+func sendToPeers(ctx context.Context, peers []*Peer, prop *peer.SignedProposal) []*PeerResponse {
 	ch := make(chan *PeerResponse)
 	l := len(peers)
 	resp := make([]*PeerResponse, 0, l)
 	for _, p := range peers {
-		go p.Endorse(ch, prop)
+		go p.EndorseContext(ctx, ch, prop)
 	}
 	for i := 0; i < l; i++ {
 		resp = append(resp, <-ch)
@@ -203,7 +322,7 @@ func sendToPeers(peers []*Peer, prop *peer.SignedProposal) []*PeerResponse {
 	return resp
 }
 
-func createTransactionProposal(identity Identity, cc ChainCode) (*transactionProposal, error) {
+func createTransactionProposal(identity Identity, cc ChainCode, tlsCertHash []byte) (*transactionProposal, error) {
 	spec, err := chainCodeInvocationSpec(cc)
 	if err != nil {
 		return nil, err
@@ -218,7 +337,7 @@ func createTransactionProposal(identity Identity, cc ChainCode) (*transactionPro
 	}
 
 	extension := &peer.ChaincodeHeaderExtension{ChaincodeId: &peer.ChaincodeID{Name: cc.Name}}
-	channelHeader, err := channelHeader(common.HeaderType_ENDORSER_TRANSACTION, txId, cc.ChannelId, 0, extension)
+	channelHeader, err := channelHeader(common.HeaderType_ENDORSER_TRANSACTION, txId, cc.ChannelId, 0, extension, tlsCertHash)
 	if err != nil {
 		return nil, err
 	}
@@ -271,7 +390,7 @@ func createTransaction(proposal []byte, endorsement []*PeerResponse) ([]byte, er
 			return nil, ErrBadTransactionStatus
 		}
 		if bytes.Compare(pl, e.Response.Payload) != 0 {
-			return nil, ErrEndorsementsDoNotMatch
+			return nil, newEndorsementMismatchError(endorsement)
 		}
 	}
 
@@ -353,14 +472,3 @@ func getChainCodeProposalPayload(bytes []byte) (*peer.ChaincodeProposalPayload,
 	}
 	return cpp, err
 }
-
-// TODO not fully implemented!
-func decodeTransaction(payload []byte) (int32, error) {
-	transaction := new(peer.ProcessedTransaction)
-	if err := proto.Unmarshal(payload, transaction); err != nil {
-		return 0, err
-	}
-	//DecodeBlockEnvelope(transaction.TransactionEnvelope)
-	return transaction.ValidationCode, nil
-
-}
@@ -0,0 +1,236 @@
+/*
+Copyright: Cognition Foundry. All Rights Reserved.
+License: Apache License Version 2.0
+*/
+package gohfc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// EndorsementVerifier checks endorsements before transaction is send to orderer.
+// Set `client.EndorsementVerifier` to enable verification. Every endorsement signature is checked using endorser
+// certificate and proposal hash in the response must match the proposal that was send.
+// If MSPs is not nil, endorser certificate must also be issued by root or intermediate CA of endorser MSP.
+// Both proposal hash and endorsement signatures are checked with the hash of client CryptoSuite, so it must be the
+// hash family used by peers (SHA2-256 by default).
+// Use `client.ChannelEndorsementVerifier` to load MSPs from channel configuration.
+type EndorsementVerifier struct {
+	// MSPs are trusted certificates for every MSP id
+	MSPs map[string]*MSPCertificates
+}
+
+// MSPCertificates are CA certificates of single MSP
+type MSPCertificates struct {
+	Roots         *x509.CertPool
+	Intermediates *x509.CertPool
+}
+
+// EndorsementVerificationError is returned when endorsement from peer cannot be verified. Transaction is not send to
+// orderer in this case.
+type EndorsementVerificationError struct {
+	Peer string
+	// Err is one of ErrEndorsementSignatureInvalid, ErrProposalHashMismatch, ErrEndorserCertificateInvalid,
+	// ErrEndorserMSPUnknown or decoding error
+	Err error
+}
+
+func (e *EndorsementVerificationError) Error() string {
+	return fmt.Sprintf("endorsement from peer %s cannot be verified: %v", e.Peer, e.Err)
+}
+
+// ChannelEndorsementVerifier returns verifier that trusts MSPs from latest channel configuration block.
+// Configuration block is fetched from the peer using CSCC `GetConfigBlock`.
+func (c *FabricClient) ChannelEndorsementVerifier(ctx context.Context, identity Identity, channelId string, peerName string) (*EndorsementVerifier, error) {
+	chainCode := ChainCode{
+		ChannelId: channelId,
+		Name:      CSCC,
+		Type:      ChaincodeSpec_GOLANG,
+		Args:      []string{"GetConfigBlock", channelId},
+	}
+	responses, err := c.QueryContext(ctx, identity, chainCode, []string{peerName})
+	if err != nil {
+		return nil, err
+	}
+	r := responses[0]
+	if r.Error != nil {
+		return nil, r.Error
+	}
+	if r.Response.Response.Status != 200 {
+		return nil, ErrBadTransactionStatus
+	}
+	block := new(common.Block)
+	if err := proto.Unmarshal(r.Response.Response.Payload, block); err != nil {
+		return nil, err
+	}
+	return newEndorsementVerifierFromBlock(block)
+}
+
+func newEndorsementVerifierFromBlock(block *common.Block) (*EndorsementVerifier, error) {
+	if block.Data == nil || len(block.Data.Data) == 0 {
+		return nil, ErrInvalidConfigBlock
+	}
+	envelope := new(common.Envelope)
+	if err := proto.Unmarshal(block.Data.Data[0], envelope); err != nil {
+		return nil, err
+	}
+	payload := new(common.Payload)
+	if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+		return nil, err
+	}
+	config := new(common.ConfigEnvelope)
+	if err := proto.Unmarshal(payload.Data, config); err != nil {
+		return nil, err
+	}
+	if config.Config == nil || config.Config.ChannelGroup == nil {
+		return nil, ErrInvalidConfigBlock
+	}
+	verifier := &EndorsementVerifier{MSPs: make(map[string]*MSPCertificates)}
+	if err := verifier.addMSPs(config.Config.ChannelGroup); err != nil {
+		return nil, err
+	}
+	return verifier, nil
+}
+
+// addMSPs walks config groups and adds every MSP definition it finds
+func (v *EndorsementVerifier) addMSPs(group *common.ConfigGroup) error {
+	if value, ok := group.Values["MSP"]; ok {
+		mspConfig := new(msp.MSPConfig)
+		if err := proto.Unmarshal(value.Value, mspConfig); err != nil {
+			return err
+		}
+		// only Fabric MSPs have certificates, idemix MSPs are skipped
+		if mspConfig.Type == 0 {
+			fabricConfig := new(msp.FabricMSPConfig)
+			if err := proto.Unmarshal(mspConfig.Config, fabricConfig); err != nil {
+				return err
+			}
+			certs := &MSPCertificates{Roots: x509.NewCertPool(), Intermediates: x509.NewCertPool()}
+			for _, c := range fabricConfig.RootCerts {
+				certs.Roots.AppendCertsFromPEM(c)
+			}
+			for _, c := range fabricConfig.IntermediateCerts {
+				certs.Intermediates.AppendCertsFromPEM(c)
+			}
+			v.MSPs[fabricConfig.Name] = certs
+		}
+	}
+	for _, g := range group.Groups {
+		if err := v.addMSPs(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verify checks all successful endorsements. Failed responses are left to `createTransaction`.
+func (v *EndorsementVerifier) verify(crypto CryptoSuite, proposal []byte, responses []*PeerResponse) error {
+	hash, err := proposalHash(crypto, proposal)
+	if err != nil {
+		return err
+	}
+	for _, r := range responses {
+		if r.Err != nil || r.Response.GetResponse().GetStatus() != 200 {
+			continue
+		}
+		if err := v.verifyResponse(crypto, hash, r.Response); err != nil {
+			return &EndorsementVerificationError{Peer: r.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+func (v *EndorsementVerifier) verifyResponse(crypto CryptoSuite, hash []byte, response *peer.ProposalResponse) error {
+	if response.Endorsement == nil {
+		return ErrEndorsementSignatureInvalid
+	}
+	responsePayload := new(peer.ProposalResponsePayload)
+	if err := proto.Unmarshal(response.Payload, responsePayload); err != nil {
+		return err
+	}
+	if !bytes.Equal(responsePayload.ProposalHash, hash) {
+		return ErrProposalHashMismatch
+	}
+
+	endorser := new(msp.SerializedIdentity)
+	if err := proto.Unmarshal(response.Endorsement.Endorser, endorser); err != nil {
+		return err
+	}
+	block, _ := pem.Decode(endorser.IdBytes)
+	if block == nil {
+		return ErrEndorserCertificateInvalid
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return ErrEndorserCertificateInvalid
+	}
+	sig := new(eCDSASignature)
+	if _, err := asn1.Unmarshal(response.Endorsement.Signature, sig); err != nil {
+		return ErrEndorsementSignatureInvalid
+	}
+	msg := append(append([]byte{}, response.Payload...), response.Endorsement.Endorser...)
+	if !ecdsa.Verify(key, crypto.Hash(msg), sig.R, sig.S) {
+		return ErrEndorsementSignatureInvalid
+	}
+
+	if v.MSPs != nil {
+		certs, ok := v.MSPs[endorser.Mspid]
+		if !ok {
+			return ErrEndorserMSPUnknown
+		}
+		opts := x509.VerifyOptions{Roots: certs.Roots, Intermediates: certs.Intermediates,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+		if _, err := cert.Verify(opts); err != nil {
+			return ErrEndorserCertificateInvalid
+		}
+	}
+	return nil
+}
+
+// proposalHash computes hash of the proposal same way as endorsing peer does: hash of channel header, signature
+// header and chaincode proposal payload without transient map.
+func proposalHash(crypto CryptoSuite, proposal []byte) ([]byte, error) {
+	prop, err := getProposal(proposal)
+	if err != nil {
+		return nil, err
+	}
+	header, err := getHeader(prop.Header)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := getChainCodeProposalPayload(prop.Payload)
+	if err != nil {
+		return nil, err
+	}
+	payloadBytes, err := proto.Marshal(&peer.ChaincodeProposalPayload{Input: payload.Input})
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, 0, len(header.ChannelHeader)+len(header.SignatureHeader)+len(payloadBytes))
+	msg = append(msg, header.ChannelHeader...)
+	msg = append(msg, header.SignatureHeader...)
+	msg = append(msg, payloadBytes...)
+	return crypto.Hash(msg), nil
+}
+
+// verifyEndorsements runs endorsement verifier if it is configured
+func (c *FabricClient) verifyEndorsements(proposal []byte, responses []*PeerResponse) error {
+	if c.EndorsementVerifier == nil {
+		return nil
+	}
+	return c.EndorsementVerifier.verify(c.Crypto, proposal, responses)
+}